@@ -1,5 +1,3 @@
 module github.com/kenjgibson/sudoku/main
 
 go 1.15
-
-require github.com/kenjgibson/sudoku v0.0.0-20210112182212-f324909e52df
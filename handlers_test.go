@@ -0,0 +1,190 @@
+// "go test" program for the HTTP handlers, run in-process against
+// recorded responses rather than a live server
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kenjgibson/sudoku/main/sudoku"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Send a request straight to a handler and return the response
+func serve(handler http.HandlerFunc, method, target, contentType, body string) *httptest.ResponseRecorder {
+	reqP := httptest.NewRequest(method, target, strings.NewReader(body))
+	if contentType != "" {
+		reqP.Header.Set("Content-Type", contentType)
+	}
+	respP := httptest.NewRecorder()
+	handler(respP, reqP)
+	return respP
+}
+
+// JSON body for a request
+func jsonBody(v interface{}) string {
+	body, _ := json.Marshal(v)
+	return string(body)
+}
+
+func TestSolveHandler(t *testing.T) {
+
+	resp := serve(solver, http.MethodPost, "/sudoku/solve", contType, jsonBody(sudoku.JsonGrid{Solution: easyGrid}))
+	var jGrid sudoku.JsonGrid
+	if err := json.NewDecoder(resp.Body).Decode(&jGrid); err != nil || resp.Code != http.StatusOK ||
+		jGrid.Status != "Success" || !jGrid.Unique {
+		t.Error(fmt.Sprintf("Easy puzzle not solved.  Returned: %d, %v, %+v", resp.Code, err, jGrid))
+	}
+
+	if resp := serve(solver, http.MethodPost, "/sudoku/solve", contType, "{"); resp.Code != http.StatusBadRequest {
+		t.Error(fmt.Sprintf("Bad JSON.  Expected 400.  Returned: %d", resp.Code))
+	}
+	if resp := serve(solver, http.MethodPut, "/sudoku/solve", "", ""); resp.Code != http.StatusMethodNotAllowed {
+		t.Error(fmt.Sprintf("Put.  Expected 405.  Returned: %d", resp.Code))
+	}
+}
+
+func TestStepsHandler(t *testing.T) {
+
+	resp := serve(steps, http.MethodPost, "/sudoku/steps", contType, jsonBody(sudoku.JsonSteps{Solution: easyGrid}))
+	var jSteps struct { // Techniques are sent by name
+		Steps []struct {
+			Technique string `json:"technique"`
+		} `json:"steps"`
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&jSteps); err != nil || resp.Code != http.StatusOK ||
+		jSteps.Status != "Success" || len(jSteps.Steps) == 0 || jSteps.Steps[0].Technique != "Naked Single" {
+		t.Error(fmt.Sprintf("Easy puzzle steps not found.  Returned: %d, %v, %+v", resp.Code, err, jSteps))
+	}
+
+	if resp := serve(steps, http.MethodPost, "/sudoku/steps", contType, "{"); resp.Code != http.StatusBadRequest {
+		t.Error(fmt.Sprintf("Bad JSON.  Expected 400.  Returned: %d", resp.Code))
+	}
+}
+
+func TestGradeHandler(t *testing.T) {
+
+	resp := serve(grade, http.MethodPost, "/sudoku/grade", contType, jsonBody(map[string]interface{}{"puzzle": easyGrid}))
+	var jGrade struct { // Techniques are sent by name
+		Difficulty string `json:"difficulty"`
+		Hardest    string `json:"hardest"`
+		Status     string `json:"status"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&jGrade); err != nil || resp.Code != http.StatusOK ||
+		jGrade.Status != "Success" || jGrade.Difficulty != "easy" {
+		t.Error(fmt.Sprintf("Easy puzzle not graded.  Returned: %d, %v, %+v", resp.Code, err, jGrade))
+	}
+
+	if resp := serve(grade, http.MethodPost, "/sudoku/grade", contType, "{"); resp.Code != http.StatusBadRequest {
+		t.Error(fmt.Sprintf("Bad JSON.  Expected 400.  Returned: %d", resp.Code))
+	}
+}
+
+func TestGenerateHandler(t *testing.T) {
+
+	resp := serve(generate, http.MethodGet, "/sudoku/generate?seed=42&symmetry=rotational&difficulty=easy", "", "")
+	var jGen sudoku.JsonGenerated
+	if err := json.NewDecoder(resp.Body).Decode(&jGen); err != nil || resp.Code != http.StatusOK ||
+		jGen.Status != "Success" || jGen.Seed != 42 || jGen.Symmetry != sudoku.Rotational || jGen.Difficulty != sudoku.Easy {
+		t.Error(fmt.Sprintf("Puzzle not generated.  Returned: %d, %v, %+v", resp.Code, err, jGen))
+	}
+
+	if resp := serve(generate, http.MethodGet, "/sudoku/generate?symmetry=x", "", ""); resp.Code != http.StatusBadRequest {
+		t.Error(fmt.Sprintf("Bad symmetry.  Expected 400.  Returned: %d", resp.Code))
+	}
+	if resp := serve(generate, http.MethodPost, "/sudoku/generate", contType, "{}"); resp.Code != http.StatusMethodNotAllowed {
+		t.Error(fmt.Sprintf("Post.  Expected 405.  Returned: %d", resp.Code))
+	}
+}
+
+func TestHintHandler(t *testing.T) {
+
+	resp := serve(hint, http.MethodPost, "/sudoku/hint", contType, jsonBody(sudoku.JsonHint{Puzzle: easyGrid}))
+	var jHint struct { // Techniques are sent by name
+		Hint *struct {
+			Technique string `json:"technique"`
+		} `json:"hint"`
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&jHint); err != nil || resp.Code != http.StatusOK ||
+		jHint.Status != "Success" || jHint.Hint == nil || jHint.Hint.Technique != "Naked Single" {
+		t.Error(fmt.Sprintf("No hint for easy puzzle.  Returned: %d, %v, %+v", resp.Code, err, jHint))
+	}
+
+	if resp := serve(hint, http.MethodPost, "/sudoku/hint", contType, "{"); resp.Code != http.StatusBadRequest {
+		t.Error(fmt.Sprintf("Bad JSON.  Expected 400.  Returned: %d", resp.Code))
+	}
+}
+
+func TestMistakesHandler(t *testing.T) {
+
+	entries := easyGrid
+	entries[0][0] = 4 // Should be 6
+	resp := serve(mistakes, http.MethodPost, "/sudoku/mistakes", contType,
+		jsonBody(sudoku.JsonMistakes{Givens: easyGrid, Entries: entries}))
+	var jMistakes sudoku.JsonMistakes
+	if err := json.NewDecoder(resp.Body).Decode(&jMistakes); err != nil || resp.Code != http.StatusOK ||
+		jMistakes.Status != "Success" || len(jMistakes.Mistakes) != 1 || jMistakes.Mistakes[0] != (sudoku.Cel{Row: 0, Col: 0}) {
+		t.Error(fmt.Sprintf("Mistake not found.  Returned: %d, %v, %+v", resp.Code, err, jMistakes))
+	}
+
+	if resp := serve(mistakes, http.MethodPost, "/sudoku/mistakes", contType, "{"); resp.Code != http.StatusBadRequest {
+		t.Error(fmt.Sprintf("Bad JSON.  Expected 400.  Returned: %d", resp.Code))
+	}
+}
+
+func TestCandidatesHandler(t *testing.T) {
+
+	resp := serve(candidates, http.MethodPost, "/sudoku/candidates", contType, jsonBody(sudoku.JsonCandidates{Puzzle: easyGrid}))
+	var jCands sudoku.JsonCandidates
+	if err := json.NewDecoder(resp.Body).Decode(&jCands); err != nil || resp.Code != http.StatusOK ||
+		jCands.Status != "Success" || fmt.Sprint(jCands.Candidates[0][0]) != "[4 5 6]" {
+		t.Error(fmt.Sprintf("Wrong candidates.  Returned: %d, %v, %+v", resp.Code, err, jCands))
+	}
+
+	if resp := serve(candidates, http.MethodPost, "/sudoku/candidates", contType, "{"); resp.Code != http.StatusBadRequest {
+		t.Error(fmt.Sprintf("Bad JSON.  Expected 400.  Returned: %d", resp.Code))
+	}
+}
+
+func TestMultiHandler(t *testing.T) {
+
+	// A blank twodoku, but for one value in the shared box
+	jMulti := sudoku.JsonMulti{Layout: "twodoku", Grids: make([]sudoku.JsonSubGrid, 2)}
+	jMulti.Grids[0].Solution[8][8] = 4
+	resp := serve(multi, http.MethodPost, "/sudoku/multi", contType, jsonBody(jMulti))
+	jMulti = sudoku.JsonMulti{}
+	if err := json.NewDecoder(resp.Body).Decode(&jMulti); err != nil || resp.Code != http.StatusOK ||
+		jMulti.Status != "Success" || len(jMulti.Grids) != 2 || jMulti.Grids[1].Solution[2][2] != 4 {
+		t.Error(fmt.Sprintf("Twodoku not solved.  Returned: %d, %v, %+v", resp.Code, err, jMulti))
+	}
+
+	if resp := serve(multi, http.MethodPost, "/sudoku/multi", contType, "{"); resp.Code != http.StatusBadRequest {
+		t.Error(fmt.Sprintf("Bad JSON.  Expected 400.  Returned: %d", resp.Code))
+	}
+}
+
+func TestSolveTextHandler(t *testing.T) {
+
+	puzzle := "3.5.71..9...34.....9.2......3...4....6......7.....285........8..54...9.1..7...4.."
+	soln := "345871269726349518891256734538764192162598347479132856913427685254683971687915423"
+	resp := serve(solver, http.MethodPost, "/sudoku/solve", "text/plain; charset=utf-8", puzzle+"\n")
+	if got := resp.Body.String(); resp.Code != http.StatusOK || got != soln+"\n" {
+		t.Error(fmt.Sprintf("Text puzzle not solved.  Returned: %d, %s", resp.Code, got))
+	}
+
+	resp = serve(solver, http.MethodPost, "/sudoku/solve", "text/plain", puzzle[:80])
+	if got := resp.Body.String(); resp.Code != http.StatusBadRequest || !strings.HasPrefix(got, "bad_format") {
+		t.Error(fmt.Sprintf("Short line.  Expected 400 bad_format.  Returned: %d, %s", resp.Code, got))
+	}
+
+	// Two 3s in the first row
+	resp = serve(solver, http.MethodPost, "/sudoku/solve", "text/plain", "33"+puzzle[2:])
+	if got := resp.Body.String(); resp.Code != http.StatusUnprocessableEntity || !strings.HasPrefix(got, "conflict") {
+		t.Error(fmt.Sprintf("Conflicting puzzle.  Expected 422 conflict.  Returned: %d, %s", resp.Code, got))
+	}
+}
@@ -24,14 +24,24 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"github.com/kenjgibson/sudoku/sudoku"
+	"github.com/kenjgibson/sudoku/main/sudoku"
+	"io"
+	"io/ioutil"
 	"log"
+	"mime"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 )
 
+// Maximum time spent solving a single request.  Can be overridden
+// with the SOLVE_TIMEOUT environment var, e.g. "500ms" or "30s"
+var solveTimeout = 10 * time.Second
+
 var getString = `Sudoku Solver API.
 
 Invoke at this endpoint using POST, Content-Type application/json,
@@ -39,19 +49,339 @@ and with body containing the following Go/JSON struct representing
 the Sudoku game to solve:
 
 type JsonGrid struct {
-	Solution Grid
-	Status   string
+	Solution       Grid
+	Status         string
+	Unique         bool
+	Solver         string
+	Code           string
+	Size           int
+	BoxRows        int
+	BoxCols        int
+	Cels           [][]uint8
+	Diagonals      bool
+	Windows        bool
+	AntiKnight     bool
+	AntiKing       bool
+	NonConsecutive bool
+	Regions        [][]int
+	Extra          [][]Cel
+	Cages          []Cage
+	Thermos        [][]Cel
+	Arrows         []Arrow
+	Sandwiches     []Sandwich
+	Odd            []Cel
+	Even           []Cel
+	White          []Pair
+	Black          []Pair
+	X              []Pair
+	V              []Pair
+	Greater        []Pair
+	NegativeKropki bool
+	NegativeXV     bool
+	Conflicts      []Conflict
 }
 
 Where type Grid is a 9x9 array of uint8 values with 0 representing a blank cel.
 
+Other sizes from 4x4 up to 25x25 are sent in Cels instead of Solution,
+as Size rows of Size values each, and are solved into Cels, with no
+Solution in the response.  Boxes are BoxRows by BoxCols cels, and
+default to the squarest shape for the size: 2x2 for 4, 2x3 for 6, 3x4
+for 12, 4x4 for 16 and so on.  Size 0 or 9 is the standard grid.
+
+Diagonals selects Sudoku X, where both main diagonals must also hold each
+value once.  Clashes on a diagonal are reported with a unit kind of
+"diagonal", index 0 running from top left and 1 from top right.
+
+Windows selects Hyper Sudoku (Windoku), where the windows between the
+boxes must also hold each value once.  A window is the size of a box and
+starts one cel in from the boxes around it, so a 9x9 grid has four, at
+rows and columns 1 to 3 and 5 to 7.  Clashes in a window are reported
+with a unit kind of "window", numbered left to right and top to bottom.
+
+Extra lists further regions of the puzzle's own, each a list of cels,
+e.g. [[{"row": 0, "col": 0}, {"row": 4, "col": 4}, {"row": 8, "col": 8}]]
+No value may repeat in an extra region, and one with a cel for every
+value must hold each of them.  Extra regions may overlap the boxes and
+each other.  One that is empty, too long or leaves the grid fails with
+Code bad_regions.  Clashes are reported with a unit kind of "extra".
+
+AntiKnight forbids the same value in two cels a knight's move apart, and
+AntiKing in two cels a king's move apart, including diagonally.  They can
+be combined with each other and with any of the rules above.  Clashes are
+reported with a unit kind of "knight" or "king", index 0.
+
+NonConsecutive forbids consecutive values in two cels side by side or
+one above the other.  It can be combined with any of the other rules.
+Givens that break it fail with Code constraint, naming the pair with a
+unit kind of "nonconsecutive".
+
+Regions makes a jigsaw puzzle.  It gives the region of every cel, by row
+and column, numbered from 0, and the regions replace the boxes.  Each
+region must be one connected group of cels, as many as the side of the
+grid, or the request fails with Code bad_regions.  Clashes in a region
+are reported with a unit kind of "region".  Blank or nearly blank jigsaw
+grids can take the "backtrack" solver a long time, so "dlx" is the
+better choice for them.
+
+Cages makes a killer puzzle.  Each cage lists its cels and the sum of
+their values, and no value may repeat in a cage, e.g.
+	{"cels": [{"row": 0, "col": 0}, {"row": 0, "col": 1}], "sum": 11}
+The grid may be left fully blank.  Cages can be used up to 16x16.  Cages
+that overlap, leave the grid or cannot make their sums fail with Code
+bad_cages, and givens that break a sum fail with cage_sum.  Clashes in a
+cage are reported with a unit kind of "cage", numbered in request order.
+
+Thermos, Arrows and Sandwiches add line constraints, each numbered from
+0 in request order.  A thermometer lists its cels from the bulb, and the
+values must increase along it.  An arrow gives a circle cel and the cels
+of its line, which must add up to the circle, e.g.
+	{"circle": {"row": 0, "col": 0}, "line": [{"row": 1, "col": 1}, {"row": 2, "col": 2}]}
+A sandwich gives a row or column and the sum of the values between the 1
+and the largest value in it, e.g.
+	{"line": {"kind": "column", "index": 3}, "sum": 12}
+They can be combined with each other and with any of the rules above.  A
+constraint that leaves the grid, repeats a cel or cannot be met fails
+with Code bad_constraints, and givens that break one fail with constraint,
+naming it with a unit kind of "thermo", "arrow" or "sandwich".
+
+Odd and Even shade cels of the grid, which must then hold an odd or an
+even value.  A cel shaded both ways, or off the grid, fails with Code
+bad_constraints, and a given of the wrong parity fails with constraint,
+naming it with a unit kind of "odd" or "even", numbered in request order.
+
+White, Black, X, V and Greater add marks between two neighbouring cels,
+side by side or one above the other, e.g.
+	{"a": {"row": 0, "col": 0}, "b": {"row": 0, "col": 1}}
+The values at a white Kropki dot differ by 1, and at a black dot one is
+twice the other.  The values at an X add up to 10, and at a V to 5.  At
+a greater-than sign the value in a is larger than the one in b.  With
+NegativeKropki the dots given are all there are, so neighbours without
+a dot are neither consecutive nor 1:2, and NegativeXV does the same for
+X and V.  A mark between cels that are not neighbours, or that cannot
+be met on the grid, fails with Code bad_constraints, and givens that
+break one fail with constraint, naming it with a unit kind of "white",
+"black", "x", "v" or "greater", or "kropki" or "xv" for an unmarked pair.
+
 The service will populate the Status field with a status string.  If a solution
-is possible, the Solution grid will contain a solved Sudoku puzzle.`
+is possible, the Solution grid will contain a solved Sudoku puzzle and Unique
+will report whether it is the only solution.
+
+Solver optionally selects the solver backend: "backtrack" (the default)
+or "dlx" for the Dancing Links exact cover solver.
+
+Code is empty on success.  Otherwise it holds a machine-readable error code:
+out_of_range, conflict, no_candidates, unsolvable, timeout, canceled,
+unknown_solver, bad_size, bad_regions, bad_cages, cage_sum,
+bad_constraints, constraint or bad_format.
+
+For a conflict, Conflicts lists every pair of cels holding the same value
+in a unit, e.g.
+	{"unit": {"kind": "box", "index": 5}, "value": 5,
+	 "cels": [{"row": 3, "col": 8}, {"row": 4, "col": 6}]}
+The unit kind is "row", "column" or "box", "region" for a jigsaw region,
+"cage" for a killer cage, or that of the variant rule broken: "diagonal",
+"window", "extra", "knight" or "king".  Units are numbered from 0.
+
+A standard puzzle can also be sent with Content-Type text/plain, as one
+line of 81 characters, row by row, with a digit for each given and a .
+or 0 for each blank cel.  The response is the solved line.  A line that
+cannot be read fails with 400 and Code bad_format, and a puzzle that
+cannot be solved with 422, each with a body of the Code and Status.`
+
+var stepsGetString = `Sudoku Solver step by step API.
+
+Invoke at this endpoint using POST, Content-Type application/json,
+and with body containing the following Go/JSON struct, with the
+Sudoku game to solve in Solution:
+
+type JsonSteps struct {
+	Solution Grid
+	Steps    []Step
+	Status   string
+	Code     string
+}
+
+The service solves the puzzle the way a person would and fills in Steps
+with each deduction in order, along with the solved grid, Status and Code
+as for the /sudoku/solve endpoint.  Each step has the form:
+
+type Step struct {
+	Technique   string         // e.g. "Hidden Single", "X-Wing"
+	Placed      []Placement    // {"row", "col", "value"} placed by the step
+	Eliminated  []Elimination  // {"row", "col", "values"} removed by the step
+	Because     []Cel          // {"row", "col"} cels that justify the step
+	Units       []Unit         // Rows, columns or boxes the step works within
+	Explanation string         // Written explanation for a player
+}
+
+Rows, columns and boxes are numbered from 0, except in Explanation, which
+numbers them from 1 the way players do (r3c4 is row 3, column 4).`
+
+var gradeGetString = `Sudoku difficulty grading API.
+
+Invoke at this endpoint using POST, Content-Type application/json,
+and with body containing the following Go/JSON struct, with the
+Sudoku game to grade in Puzzle:
+
+type JsonGrade struct {
+	Puzzle      Grid
+	Status      string
+	Code        string
+	Difficulty  string          // easy, medium, hard, expert or extreme
+	Score       int
+	Counts      map[string]int  // Uses of each technique, by name
+	Hardest     string          // Hardest technique needed
+	SearchNodes int             // Nodes searched when logic alone stalls
+}
+
+The service solves the puzzle the way a person would and scores it
+from the hardest technique needed, the number of times each technique
+is used and how much search was needed.  Status and Code are as for
+the /sudoku/solve endpoint.  Puzzle is returned unchanged.`
+
+var hintGetString = `Sudoku hint API.
+
+Invoke at this endpoint using POST, Content-Type application/json,
+and with body containing the following Go/JSON struct, with the
+partly filled Sudoku game in Puzzle:
+
+type JsonHint struct {
+	Puzzle    Grid
+	Hint      Step
+	Status    string
+	Code      string
+	Conflicts []Conflict
+}
+
+The service fills in Hint with the easiest deduction that can be made
+next, in the same form as the steps from the /sudoku/steps endpoint.
+Puzzle is returned unchanged.  If the grid has no solution, an entry is
+wrong and Code is unsolvable.  Code is solved if there are no blank cels.
+Otherwise Status, Code and Conflicts are as for /sudoku/solve.`
+
+var mistakesGetString = `Sudoku mistake checking API.
+
+Invoke at this endpoint using POST, Content-Type application/json,
+and with body containing the following Go/JSON struct, with the
+original puzzle in Givens and the player's grid in Entries:
+
+type JsonMistakes struct {
+	Givens      Grid
+	Entries     Grid
+	Status      string
+	Code        string
+	Mistakes    []Cel  // {"row", "col"} of each wrong entry
+	Completable bool
+}
+
+Entries may repeat the givens or leave them blank, but may not change
+them (Code given_changed).  The service compares each entry with the
+solution of the givens, lists the wrong ones in Mistakes, and sets
+Completable if the grid can still be completed.  The givens must have
+exactly one solution (Code not_unique otherwise).  Status and Code are
+otherwise as for the /sudoku/solve endpoint.`
+
+var candidatesGetString = `Sudoku candidates (pencil marks) API.
+
+Invoke at this endpoint using POST, Content-Type application/json,
+and with body containing the following Go/JSON struct, with the
+partly filled Sudoku game in Puzzle:
+
+type JsonCandidates struct {
+	Puzzle         Grid
+	Marks          CandidateGrid  // Optional player pencil marks
+	Eliminate      bool
+	Candidates     CandidateGrid
+	Steps          []Step
+	Status         string
+	Code           string
+	Diagonals      bool
+	Windows        bool
+	AntiKnight     bool
+	AntiKing       bool
+	NonConsecutive bool
+	Thermos        [][]Cel
+	Arrows         []Arrow
+	Sandwiches     []Sandwich
+	Odd            []Cel
+	Even           []Cel
+	White          []Pair
+	Black          []Pair
+	X              []Pair
+	V              []Pair
+	Greater        []Pair
+	NegativeKropki bool
+	NegativeXV     bool
+}
+
+Where type CandidateGrid is a 9x9 array of lists of values, e.g.
+[[[], [1, 4, 7], ...], ...], with an empty list for a filled cel.
+
+The service fills in Candidates with the legal values of every blank cel.
+If Marks is given, or Eliminate is set, it starts from the player's marks
+instead (all legal values for a cel with no marks) and crosses off every
+candidate the logical techniques can rule out, listing the deductions in
+Steps in the same form as the /sudoku/steps endpoint.  Cels are never
+filled in.  The variant rules and constraints are those of the
+/sudoku/solve endpoint, and also rule out candidates.  Status and Code
+are as for the /sudoku/solve endpoint.`
+
+var multiGetString = `Sudoku multi-grid (Samurai, Twodoku, Butterfly) API.
+
+Invoke at this endpoint using POST, Content-Type application/json,
+and with body containing the following Go/JSON struct:
+
+type JsonMulti struct {
+	Layout string
+	Grids  []JsonSubGrid
+	Solver string
+	Unique bool
+	Status string
+	Code   string
+}
+
+type JsonSubGrid struct {
+	Solution Grid
+	Status   string
+	Code     string
+}
+
+Layout is "samurai" (five grids, the middle one sharing a corner box
+with each of the others), "twodoku" (two grids sharing a corner box) or
+"butterfly" (four grids overlapping in a 12x12 square).  Grids holds
+each grid of the layout, top to bottom and left to right, with its
+givens in Solution.  A shared cel may be given in any grid holding it.
+
+The grids are solved together, and each Solution is replaced by that
+grid's part of the joint solution.  Status, Code and Unique report on
+the puzzle as a whole, as for the /sudoku/solve endpoint, and Code is
+bad_layout for an unknown layout or the wrong number of grids.  The
+Status and Code of each grid report any error found in that grid, with
+cels in its own rows and columns.  A clash between grids on a shared cel
+is reported in each of them.`
 
 func main() {
 	log.Print("Starting Sudoku server...")
 
 	http.HandleFunc("/sudoku/solve", solver)
+	http.HandleFunc("/sudoku/steps", steps)
+	http.HandleFunc("/sudoku/grade", grade)
+	http.HandleFunc("/sudoku/generate", generate)
+	http.HandleFunc("/sudoku/hint", hint)
+	http.HandleFunc("/sudoku/mistakes", mistakes)
+	http.HandleFunc("/sudoku/candidates", candidates)
+	http.HandleFunc("/sudoku/multi", multi)
+
+	// Determine if the solve deadline is set by environment var
+	if timeout := os.Getenv("SOLVE_TIMEOUT"); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			log.Fatalf("invalid SOLVE_TIMEOUT %s: %v", timeout, err)
+		}
+		solveTimeout = d
+	}
+	log.Printf("solve timeout %v", solveTimeout)
 
 	// Determine if Port to use is set by environment var
 	port := os.Getenv("PORT")
@@ -79,31 +409,290 @@ func solver(respP http.ResponseWriter, reqP *http.Request) {
 		return
 
 	case http.MethodPost:
-		var jGrid sudoku.JsonGrid
-
-		decoder := json.NewDecoder(reqP.Body)
-		if err := decoder.Decode(&jGrid); err != nil {
-			err = fmt.Errorf("Can't decode JSON: %s", err)
-			log.Printf("%v", err)
-			respP.WriteHeader(http.StatusBadRequest)
-			respP.Write([]byte("400 - Bad Request"))
+		// A puzzle sent as one line of text is answered the same way
+		if mediaType, _, _ := mime.ParseMediaType(reqP.Header.Get("Content-Type")); mediaType == "text/plain" {
+			serveText(respP, reqP)
 			return
 		}
 
-		defer reqP.Body.Close()
+		var jGrid sudoku.JsonGrid
 
-		sudoku.Jsolve(&jGrid)
+		serveJSON(respP, reqP, &jGrid, func(ctx context.Context) {
+			sudoku.JsolveContext(ctx, &jGrid)
+		})
+		return
 
-		encoder := json.NewEncoder(respP)
-		if err := encoder.Encode(jGrid); err != nil {
-			err = fmt.Errorf("Can't encode: %s", err)
-			log.Printf("%v", err)
-		}
+	default:
+		notAllowed(respP)
+	}
+}
+
+func steps(respP http.ResponseWriter, reqP *http.Request) {
+
+	switch reqP.Method {
+	case http.MethodGet:
+		fmt.Fprintf(respP, "%s\n", stepsGetString)
+		return
+
+	case http.MethodPost:
+		var jSteps sudoku.JsonSteps
+
+		serveJSON(respP, reqP, &jSteps, func(ctx context.Context) {
+			sudoku.JstepsContext(ctx, &jSteps)
+		})
+		return
+
+	default:
+		notAllowed(respP)
+	}
+}
+
+func grade(respP http.ResponseWriter, reqP *http.Request) {
+
+	switch reqP.Method {
+	case http.MethodGet:
+		fmt.Fprintf(respP, "%s\n", gradeGetString)
+		return
+
+	case http.MethodPost:
+		var jGrade sudoku.JsonGrade
+
+		serveJSON(respP, reqP, &jGrade, func(ctx context.Context) {
+			sudoku.JgradeContext(ctx, &jGrade)
+		})
+		return
+
+	default:
+		notAllowed(respP)
+	}
+}
+
+func hint(respP http.ResponseWriter, reqP *http.Request) {
+
+	switch reqP.Method {
+	case http.MethodGet:
+		fmt.Fprintf(respP, "%s\n", hintGetString)
+		return
+
+	case http.MethodPost:
+		var jHint sudoku.JsonHint
+
+		serveJSON(respP, reqP, &jHint, func(ctx context.Context) {
+			sudoku.JhintContext(ctx, &jHint)
+		})
+		return
+
+	default:
+		notAllowed(respP)
+	}
+}
+
+func mistakes(respP http.ResponseWriter, reqP *http.Request) {
+
+	switch reqP.Method {
+	case http.MethodGet:
+		fmt.Fprintf(respP, "%s\n", mistakesGetString)
+		return
+
+	case http.MethodPost:
+		var jMistakes sudoku.JsonMistakes
+
+		serveJSON(respP, reqP, &jMistakes, func(ctx context.Context) {
+			sudoku.JmistakesContext(ctx, &jMistakes)
+		})
 		return
 
 	default:
-		// Some other unsupported http verb
-		respP.WriteHeader(http.StatusMethodNotAllowed)
-		respP.Write([]byte("405 - Method Not Allowed\n"))
+		notAllowed(respP)
 	}
 }
+
+func candidates(respP http.ResponseWriter, reqP *http.Request) {
+
+	switch reqP.Method {
+	case http.MethodGet:
+		fmt.Fprintf(respP, "%s\n", candidatesGetString)
+		return
+
+	case http.MethodPost:
+		var jCands sudoku.JsonCandidates
+
+		serveJSON(respP, reqP, &jCands, func(ctx context.Context) {
+			sudoku.JcandidatesContext(ctx, &jCands)
+		})
+		return
+
+	default:
+		notAllowed(respP)
+	}
+}
+
+//  Multi-grid puzzles are solved with Post, with a JsonMulti body.  Get
+//  responds with a description of the API.
+
+func multi(respP http.ResponseWriter, reqP *http.Request) {
+
+	switch reqP.Method {
+	case http.MethodGet:
+		fmt.Fprintf(respP, "%s\n", multiGetString)
+		return
+
+	case http.MethodPost:
+		var jMulti sudoku.JsonMulti
+
+		serveJSON(respP, reqP, &jMulti, func(ctx context.Context) {
+			sudoku.JmultiContext(ctx, &jMulti)
+		})
+		return
+
+	default:
+		notAllowed(respP)
+	}
+}
+
+//  Generate is invoked with Get, with the options as query parameters:
+//	seed		Seed for the random source.  Defaults to the current time
+//	clues		Target clue count.  Defaults to as few as possible
+//	symmetry	none (default), rotational, mirror or diagonal
+//	difficulty	easy, medium, hard, expert, extreme or any (default)
+//	diagonals, windows, antiKnight, antiKing, nonConsecutive
+//			Variant rules.  Each defaults to false
+//	parity		Shade every cel odd or even.  Defaults to false
+//  Responds with a JsonGenerated struct.
+
+func generate(respP http.ResponseWriter, reqP *http.Request) {
+
+	if reqP.Method != http.MethodGet {
+		notAllowed(respP)
+		return
+	}
+
+	jGen := sudoku.JsonGenerated{Seed: time.Now().UnixNano(), Difficulty: sudoku.AnyDifficulty}
+
+	query := reqP.URL.Query()
+	var err error
+	if s := query.Get("seed"); s != "" {
+		jGen.Seed, err = strconv.ParseInt(s, 10, 64)
+	}
+	if s := query.Get("clues"); s != "" && err == nil {
+		jGen.Clues, err = strconv.Atoi(s)
+	}
+	if s := query.Get("symmetry"); s != "" && err == nil {
+		err = jGen.Symmetry.UnmarshalText([]byte(s))
+	}
+	if s := query.Get("difficulty"); s != "" && err == nil {
+		err = jGen.Difficulty.UnmarshalText([]byte(s))
+	}
+	if s := query.Get("diagonals"); s != "" && err == nil {
+		jGen.Diagonals, err = strconv.ParseBool(s)
+	}
+	if s := query.Get("windows"); s != "" && err == nil {
+		jGen.Windows, err = strconv.ParseBool(s)
+	}
+	if s := query.Get("antiKnight"); s != "" && err == nil {
+		jGen.AntiKnight, err = strconv.ParseBool(s)
+	}
+	if s := query.Get("antiKing"); s != "" && err == nil {
+		jGen.AntiKing, err = strconv.ParseBool(s)
+	}
+	if s := query.Get("nonConsecutive"); s != "" && err == nil {
+		jGen.NonConsecutive, err = strconv.ParseBool(s)
+	}
+	if s := query.Get("parity"); s != "" && err == nil {
+		jGen.Parity, err = strconv.ParseBool(s)
+	}
+	if err != nil {
+		log.Printf("Can't parse query: %v", err)
+		respP.WriteHeader(http.StatusBadRequest)
+		respP.Write([]byte("400 - Bad Request"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(reqP.Context(), solveTimeout)
+	defer cancel()
+
+	sudoku.JgenerateContext(ctx, &jGen)
+
+	encoder := json.NewEncoder(respP)
+	if err := encoder.Encode(&jGen); err != nil {
+		err = fmt.Errorf("Can't encode: %s", err)
+		log.Printf("%v", err)
+	}
+}
+
+//  Common handling for a Post request.  Decodes the JSON body into
+//  jBody, calls handle to fill in the result, then encodes jBody back
+//  as the response.  handle is given a context that ends when the client
+//  goes away or the solve deadline passes.
+
+func serveJSON(respP http.ResponseWriter, reqP *http.Request, jBody interface{}, handle func(ctx context.Context)) {
+
+	decoder := json.NewDecoder(reqP.Body)
+	if err := decoder.Decode(jBody); err != nil {
+		err = fmt.Errorf("Can't decode JSON: %s", err)
+		log.Printf("%v", err)
+		respP.WriteHeader(http.StatusBadRequest)
+		respP.Write([]byte("400 - Bad Request"))
+		return
+	}
+
+	defer reqP.Body.Close()
+
+	// Stop solving if the client goes away or the deadline passes
+	ctx, cancel := context.WithTimeout(reqP.Context(), solveTimeout)
+	defer cancel()
+
+	handle(ctx)
+
+	encoder := json.NewEncoder(respP)
+	if err := encoder.Encode(jBody); err != nil {
+		err = fmt.Errorf("Can't encode: %s", err)
+		log.Printf("%v", err)
+	}
+}
+
+//  Solve a standard puzzle sent as one line of text.  Responds with the
+//  solved line, or with the error Code and Status of the /sudoku/solve
+//  endpoint: 400 for a line that cannot be read, 422 for a puzzle that
+//  cannot be solved.
+
+func serveText(respP http.ResponseWriter, reqP *http.Request) {
+
+	defer reqP.Body.Close()
+
+	// A line is 81 characters, so anything much longer is not a puzzle
+	body, err := ioutil.ReadAll(io.LimitReader(reqP.Body, 1024))
+	if err != nil {
+		log.Printf("Can't read body: %v", err)
+		respP.WriteHeader(http.StatusBadRequest)
+		respP.Write([]byte("400 - Bad Request"))
+		return
+	}
+
+	respP.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	var jGrid sudoku.JsonGrid
+	if jGrid.Solution, err = sudoku.ParseString(string(body)); err != nil {
+		respP.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(respP, "%s: %v\n", sudoku.ErrorCode(err), err)
+		return
+	}
+
+	// Stop solving if the client goes away or the deadline passes
+	ctx, cancel := context.WithTimeout(reqP.Context(), solveTimeout)
+	defer cancel()
+
+	sudoku.JsolveContext(ctx, &jGrid)
+	if jGrid.Code != "" {
+		respP.WriteHeader(http.StatusUnprocessableEntity)
+		fmt.Fprintf(respP, "%s: %s\n", jGrid.Code, jGrid.Status)
+		return
+	}
+	fmt.Fprintf(respP, "%s\n", jGrid.Solution.String())
+}
+
+// Some other unsupported http verb
+func notAllowed(respP http.ResponseWriter) {
+	respP.WriteHeader(http.StatusMethodNotAllowed)
+	respP.Write([]byte("405 - Method Not Allowed\n"))
+}
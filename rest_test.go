@@ -10,7 +10,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"github.com/kenjgibson/sudoku/sudoku"
+	"github.com/kenjgibson/sudoku/main/sudoku"
 	"net/http"
 	"testing"
 )
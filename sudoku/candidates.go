@@ -0,0 +1,220 @@
+//
+// Copyright 2020, 2021 Kenneth J. Gibson
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//
+// Candidates (pencil marks).
+// Candidates lists the legal values of every blank cel, the way a player
+// would pencil them in.  ReduceCandidates starts from a player's own
+// pencil marks and crosses off what the logical techniques can rule out.
+// It never fills in a cel, so singles are left for the player to find.
+//
+
+package sudoku
+
+import (
+	"context"
+	"fmt"
+)
+
+// The candidates of each cel, by row and column.  Empty for a filled cel
+type CandidateGrid [GridSize][GridSize]CelVals
+
+// Convert a set of values to the exported list, in ascending order
+func valsOf(vals valSet) CelVals {
+	list := CelVals{}
+	for ; vals != 0; vals &^= valBit(vals.first()) {
+		list = append(list, vals.first())
+	}
+	return list
+}
+
+//  Public entry point for the candidates of a grid.
+//  Returns the values not yet used in the units of each blank cel.
+//  Returns ErrOutOfRange or ErrConflict for an illegal grid.  Does not
+//  check that the grid can be solved.
+
+func Candidates(configP *Grid) (CandidateGrid, error) {
+
+	return candidates(configP, Variants{}, Constraints{})
+}
+
+//  Candidates under variant rules and constraints, which also rule
+//  values out
+
+func candidates(configP *Grid, variants Variants, cs Constraints) (CandidateGrid, error) {
+
+	var cands CandidateGrid
+	var gp grid
+
+	puzzle := SizedGridOf(configP)
+	puzzle.Variants, puzzle.Constraints = variants, cs
+	if err := gp.load(&puzzle); err != nil {
+		return cands, err
+	}
+
+	for idx := 0; idx < classic.numCels; idx++ {
+		var vals valSet
+		if gp.value[idx] == Blank {
+			vals = gp.options(idx)
+		}
+		cands[idx/GridSize][idx%GridSize] = valsOf(vals)
+	}
+	return cands, nil
+}
+
+// Find the easiest deduction that only eliminates candidates.
+// Returns nil once none is left
+
+func (lg *logicGrid) nextElimination() *step {
+	for _, t := range logicOrder {
+		switch t.tech {
+		case NakedSingle, HiddenSingle, UniqueRectangle:
+			continue
+		}
+		if st := t.find(lg); st != nil {
+			return st
+		}
+	}
+	return nil
+}
+
+//  Public entry point for reducing a player's pencil marks.
+//  marksP holds the player's candidates for each blank cel.  A blank cel
+//  with no marks has not been pencilled in yet, and starts from all of its
+//  legal values, as does every cel if marksP is nil.  Marks that clash
+//  with a filled cel are dropped, then the logical techniques are applied
+//  until none can eliminate anything more.
+//
+//  Returns the reduced candidates, and the steps that eliminated them.
+//  The caller's Grid is not modified.  Returns the same errors as
+//  Candidates, ErrOutOfRange for an illegal mark, and ErrNoCandidates if
+//  the marks leave a blank cel with nothing possible.  Returns
+//  ErrUnsolvable if the marks rule out every solution.
+
+func ReduceCandidates(configP *Grid, marksP *CandidateGrid) (CandidateGrid, []Step, error) {
+
+	return ReduceCandidatesContext(context.Background(), configP, marksP)
+}
+
+//  Same as ReduceCandidates, but gives up when the context is canceled or
+//  its deadline passes.
+
+func ReduceCandidatesContext(ctx context.Context, configP *Grid, marksP *CandidateGrid) (CandidateGrid, []Step, error) {
+
+	return reduceCandidates(ctx, configP, Variants{}, Constraints{}, marksP)
+}
+
+//  ReduceCandidates under variant rules and constraints, which the
+//  techniques also use
+
+func reduceCandidates(ctx context.Context, configP *Grid, variants Variants, cs Constraints, marksP *CandidateGrid) (CandidateGrid, []Step, error) {
+
+	var lg logicGrid
+	var cands CandidateGrid
+	var steps []Step
+
+	puzzle := SizedGridOf(configP)
+	puzzle.Variants, puzzle.Constraints = variants, cs
+	if err := lg.load(&puzzle); err != nil {
+		return cands, nil, err
+	}
+	lg.initCands()
+
+	for idx := 0; idx < classic.numCels && marksP != nil; idx++ {
+		row, col := idx/GridSize, idx%GridSize
+		var marks valSet
+		for _, val := range marksP[row][col] {
+			if val < MinVal || val > MaxVal {
+				return cands, nil, &CelError{Err: ErrOutOfRange, Row: row, Col: col}
+			}
+			marks |= valBit(val)
+		}
+		if lg.value[idx] != Blank || marks == 0 {
+			continue
+		}
+		if lg.cands[idx] &= marks; lg.cands[idx] == 0 {
+			return cands, nil, &CelError{Err: ErrNoCandidates, Row: row, Col: col}
+		}
+	}
+
+	for !lg.broken() {
+		if err := ctxError(ctx); err != nil {
+			return cands, nil, err
+		}
+
+		st := lg.nextElimination()
+		if st == nil {
+			for idx := 0; idx < classic.numCels; idx++ {
+				cands[idx/GridSize][idx%GridSize] = valsOf(lg.cands[idx])
+			}
+			return cands, steps, nil
+		}
+		lg.apply(st)
+		steps = append(steps, st.export(lg.geo))
+	}
+	return cands, nil, ErrUnsolvable
+}
+
+// Exported struct for marshaling candidates to/from JSON.
+// The grid is sent in Puzzle and is not changed.  The player's pencil
+// marks, if any, are sent in Marks.
+type JsonCandidates struct {
+	Puzzle     Grid           `json:"puzzle"`
+	Marks      *CandidateGrid `json:"marks,omitempty"`
+	Eliminate  bool           `json:"eliminate"` // Apply logical eliminations.  Implied by Marks
+	Candidates CandidateGrid  `json:"candidates"`
+	Steps      []Step         `json:"steps,omitempty"` // Deductions that eliminated candidates
+	Status     string         `json:"status"`
+	Code       string         `json:"code,omitempty"` // Machine-readable error code.  See ErrorCode
+
+	// Variant rules and constraints the puzzle follows
+	Variants
+	Constraints
+}
+
+// JSON version of Candidates and ReduceCandidates.  Copies status into
+// the JsonCandidates struct the same way Jsolve does for a JsonGrid.
+
+func Jcandidates(jCandsP *JsonCandidates) {
+
+	JcandidatesContext(context.Background(), jCandsP)
+}
+
+// Same as Jcandidates, but gives up when the context is canceled or
+// its deadline passes.
+
+func JcandidatesContext(ctx context.Context, jCandsP *JsonCandidates) {
+
+	var cands CandidateGrid
+	var steps []Step
+	var err error
+
+	if jCandsP.Eliminate || jCandsP.Marks != nil {
+		cands, steps, err = reduceCandidates(ctx, &jCandsP.Puzzle, jCandsP.Variants, jCandsP.Constraints, jCandsP.Marks)
+	} else {
+		cands, err = candidates(&jCandsP.Puzzle, jCandsP.Variants, jCandsP.Constraints)
+	}
+
+	jCandsP.Candidates = cands
+	jCandsP.Steps = steps
+	if err != nil {
+		jCandsP.Status = fmt.Sprintf("%v", err)
+		jCandsP.Code = ErrorCode(err)
+		return
+	}
+	jCandsP.Status = fmt.Sprintf("Success")
+	jCandsP.Code = ""
+}
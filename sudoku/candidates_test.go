@@ -0,0 +1,134 @@
+// "go test" program for candidates and pencil marks
+
+package sudoku
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// True if val can go in the cel without repeating a value in a unit
+func legalAt(configP *Grid, row, col int, val CelVal) bool {
+	for _, unit := range classic.celUnits[row*GridSize+col] {
+		for _, idx := range classic.unitCels[unit] {
+			if configP[idx/GridSize][idx%GridSize] == val {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestCandidates(t *testing.T) {
+
+	var puzzle Grid = medGrid
+	cands, err := Candidates(&puzzle)
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Candidates failed.  Returned: %v", err))
+	}
+
+	for row := 0; row < GridSize; row++ {
+		for col := 0; col < GridSize; col++ {
+			want := CelVals{}
+			for val := MinVal; val <= MaxVal && puzzle[row][col] == Blank; val++ {
+				if legalAt(&puzzle, row, col, val) {
+					want = append(want, val)
+				}
+			}
+			if fmt.Sprint(cands[row][col]) != fmt.Sprint(want) {
+				t.Error(fmt.Sprintf("Cel %d, %d: candidates %v, want %v", row, col, cands[row][col], want))
+			}
+		}
+	}
+
+	// Candidates encode as arrays of numbers, not base64 strings
+	if b, _ := json.Marshal(CelVals{1, 2}); string(b) != "[1,2]" {
+		t.Error(fmt.Sprintf("Candidates encoded as %s", b))
+	}
+
+	puzzle = illegalGrid
+	if _, err := Candidates(&puzzle); !errors.Is(err, ErrConflict) {
+		t.Error(fmt.Sprintf("Expected ErrConflict.  Returned: %v", err))
+	}
+}
+
+func TestReduceCandidates(t *testing.T) {
+
+	var puzzle Grid = hardGrid
+	var soln Grid = hardGrid
+	Solve(&soln)
+
+	full, _ := Candidates(&puzzle)
+	reduced, steps, err := ReduceCandidates(&puzzle, nil)
+	if err != nil || len(steps) == 0 {
+		t.Fatal(fmt.Sprintf("Nothing eliminated.  Returned: %d steps, %v", len(steps), err))
+	}
+
+	// Only eliminations, never the solution value, and never a placement
+	for row := 0; row < GridSize; row++ {
+		for col := 0; col < GridSize; col++ {
+			if len(reduced[row][col]) > len(full[row][col]) {
+				t.Error(fmt.Sprintf("Cel %d, %d gained candidates", row, col))
+			}
+			if puzzle[row][col] == Blank && !valSetOf(reduced[row][col]).has(soln[row][col]) {
+				t.Error(fmt.Sprintf("Cel %d, %d lost its solution value", row, col))
+			}
+		}
+	}
+	for _, st := range steps {
+		if len(st.Placed) > 0 || len(st.Eliminated) == 0 {
+			t.Error(fmt.Sprintf("%v step does not only eliminate", st.Technique))
+		}
+	}
+
+	// A player's marks are kept, apart from those that clash
+	var marks CandidateGrid
+	blank := -1
+	for idx := 0; idx < classic.numCels && blank < 0; idx++ {
+		if puzzle[idx/GridSize][idx%GridSize] == Blank {
+			blank = idx
+		}
+	}
+	row, col := blank/GridSize, blank%GridSize
+	for val := MinVal; val <= MaxVal; val++ {
+		marks[row][col] = append(marks[row][col], val)
+	}
+	reduced, _, err = ReduceCandidates(&puzzle, &marks)
+	if err != nil || len(reduced[row][col]) > len(full[row][col]) {
+		t.Error(fmt.Sprintf("Clashing marks kept.  Returned: %v %v", reduced[row][col], err))
+	}
+
+	// Marks that rule out every value of a cel
+	marks[row][col] = CelVals{}
+	for val := MinVal; val <= MaxVal; val++ {
+		if !legalAt(&puzzle, row, col, val) {
+			marks[row][col] = CelVals{val}
+			break
+		}
+	}
+	if _, _, err := ReduceCandidates(&puzzle, &marks); !errors.Is(err, ErrNoCandidates) {
+		t.Error(fmt.Sprintf("Expected ErrNoCandidates.  Returned: %v", err))
+	}
+
+	marks[row][col] = CelVals{MaxVal + 1}
+	if _, _, err := ReduceCandidates(&puzzle, &marks); !errors.Is(err, ErrOutOfRange) {
+		t.Error(fmt.Sprintf("Expected ErrOutOfRange.  Returned: %v", err))
+	}
+
+	jCands := JsonCandidates{Puzzle: puzzle, Eliminate: true}
+	Jcandidates(&jCands)
+	if jCands.Code != "" || len(jCands.Steps) != len(steps) {
+		t.Error(fmt.Sprintf("Jcandidates failed.  Returned: %s", jCands.Status))
+	}
+}
+
+// Convert an exported list back to a set
+func valSetOf(vals CelVals) valSet {
+	var vs valSet
+	for _, val := range vals {
+		vs |= valBit(val)
+	}
+	return vs
+}
@@ -0,0 +1,489 @@
+//
+// Copyright 2020, 2021 Kenneth J. Gibson
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//
+// Line and shape constraints.
+// Variants such as thermometers relate the values of a group of cels in
+// ways a unit cannot.  Each kind implements the constraint interface:
+// given the values placed so far, it says which values a blank cel of
+// the group can still take, and whether the group is already broken.
+// The engine asks every constraint on a cel when listing the cel's
+// options, so both solvers and the logical techniques prune with them,
+// and asks them all again to check a loaded or finished grid.
+//
+// A new kind needs a field in Constraints for its JSON form, a check in
+// build, and a type implementing the interface.
+//
+
+package sudoku
+
+import (
+	"fmt"
+)
+
+// Line and shape constraints of a puzzle.  Sent in JSON alongside the
+// grid.  Each kind is numbered separately from 0 in the order given.
+type Constraints struct {
+	Thermos    [][]Cel    `json:"thermos,omitempty"`    // Values increase from the bulb, first, to the tip
+	Arrows     []Arrow    `json:"arrows,omitempty"`     // Values on the line add up to the circle
+	Sandwiches []Sandwich `json:"sandwiches,omitempty"` // Sums between the smallest and largest values of a line
+
+	// Shaded cels of an odd/even puzzle
+	Odd  []Cel `json:"odd,omitempty"`  // Cels holding odd values
+	Even []Cel `json:"even,omitempty"` // Cels holding even values
+
+	// Marks between neighbouring cels.  See pairs.go
+	White   []Pair `json:"white,omitempty"`   // Kropki white dots: the values differ by 1
+	Black   []Pair `json:"black,omitempty"`   // Kropki black dots: one value is twice the other
+	X       []Pair `json:"x,omitempty"`       // The values add up to 10
+	V       []Pair `json:"v,omitempty"`       // The values add up to 5
+	Greater []Pair `json:"greater,omitempty"` // The value in A is larger than the one in B
+
+	// Negative mode.  Neighbours without a dot are neither consecutive
+	// nor 1:2, and those without an X or V add up to neither 10 nor 5
+	NegativeKropki bool `json:"negativeKropki,omitempty"`
+	NegativeXV     bool `json:"negativeXV,omitempty"`
+}
+
+// An arrow.  The value in the circle cel is the sum of the values on the
+// line, which may repeat if the unit rules allow it.
+type Arrow struct {
+	Circle Cel   `json:"circle"`
+	Line   []Cel `json:"line"`
+}
+
+// A sandwich clue.  Line is a row or column, and Sum the total of the
+// values between the 1 and the largest value in it.
+type Sandwich struct {
+	Line Unit `json:"line"`
+	Sum  int  `json:"sum"`
+}
+
+//  A rule over a group of cels, beyond the units and cages.  options
+//  fills in the values each cel can take, by position in cels().  Only
+//  the entries for blank cels are used.  The grid keeps the result until
+//  a value in the group changes, since a cel's options are asked for far
+//  more often than they change.
+
+type constraint interface {
+	unit() Unit                      // Names the constraint in errors
+	cels() []int                     // Cels the rule covers
+	options(gp *grid, opts []valSet) // Values each cel can take
+	holds(gp *grid) bool             // False if the values placed break the rule
+}
+
+// A constraint covering a cel, and the cel's position in it
+type conRef struct {
+	con int // Index in the geometry's constraints
+	pos int // Position in the constraint's cels
+}
+
+// True if there are no constraints
+func (cs *Constraints) empty() bool {
+	return cs.Thermos == nil && cs.Arrows == nil && cs.Sandwiches == nil && cs.Odd == nil && cs.Even == nil &&
+		cs.White == nil && cs.Black == nil && cs.X == nil && cs.V == nil && cs.Greater == nil &&
+		!cs.NegativeKropki && !cs.NegativeXV
+}
+
+//  Check the constraints against a grid of the given side and build the
+//  engine form of each.  Returns ErrBadConstraints describing the first
+//  problem found.
+
+func (cs *Constraints) build(size int) ([]constraint, error) {
+
+	var built []constraint
+
+	// Converts a list of cels, which must be on the grid and different
+	onGrid := func(what string, cels []Cel) ([]int, error) {
+		seen := make(map[Cel]bool)
+		for _, cel := range cels {
+			if cel.Row < 0 || cel.Row >= size || cel.Col < 0 || cel.Col >= size {
+				return nil, fmt.Errorf("%w: %s cel %d, %d is off the grid", ErrBadConstraints, what, cel.Row, cel.Col)
+			}
+			if seen[cel] {
+				return nil, fmt.Errorf("%w: %s has cel %d, %d twice", ErrBadConstraints, what, cel.Row, cel.Col)
+			}
+			seen[cel] = true
+		}
+		return celIndexes(cels, size), nil
+	}
+
+	for i, cels := range cs.Thermos {
+		what := fmt.Sprintf("thermo %d", i)
+		if len(cels) < 2 || len(cels) > size {
+			return nil, fmt.Errorf("%w: %s has %d cels", ErrBadConstraints, what, len(cels))
+		}
+		idxs, err := onGrid(what, cels)
+		if err != nil {
+			return nil, err
+		}
+		built = append(built, &thermo{Unit{"thermo", i}, idxs})
+	}
+
+	for i, arrow := range cs.Arrows {
+		what := fmt.Sprintf("arrow %d", i)
+		if len(arrow.Line) == 0 || len(arrow.Line) > size {
+			return nil, fmt.Errorf("%w: %s has %d cels on its line", ErrBadConstraints, what, len(arrow.Line))
+		}
+		idxs, err := onGrid(what, append([]Cel{arrow.Circle}, arrow.Line...))
+		if err != nil {
+			return nil, err
+		}
+		built = append(built, &arrowRule{Unit{"arrow", i}, idxs})
+	}
+
+	for i, sw := range cs.Sandwiches {
+		kind, line := sw.Line.Kind, sw.Line.Index
+		if (kind != "row" && kind != "column") || line < 0 || line >= size {
+			return nil, fmt.Errorf("%w: sandwich %d is on %v", ErrBadConstraints, i, sw.Line)
+		}
+		// Largest possible is everything but the 1 and the largest value
+		if most := size*(size+1)/2 - 1 - size; sw.Sum < 0 || sw.Sum > most {
+			return nil, fmt.Errorf("%w: sandwich %d sum %d is not possible", ErrBadConstraints, i, sw.Sum)
+		}
+		var idxs []int
+		for j := 0; j < size; j++ {
+			if kind == "row" {
+				idxs = append(idxs, line*size+j)
+			} else {
+				idxs = append(idxs, j*size+line)
+			}
+		}
+		built = append(built, &sandwich{Unit{"sandwich", i}, idxs, sw.Sum})
+	}
+
+	shaded := make(map[Cel]string)
+	for _, p := range []struct {
+		kind string
+		cels []Cel
+		rem  int
+	}{{"odd", cs.Odd, 1}, {"even", cs.Even, 0}} {
+		for i, cel := range p.cels {
+			if _, err := onGrid(p.kind, []Cel{cel}); err != nil {
+				return nil, err
+			}
+			if kind, ok := shaded[cel]; ok && kind != p.kind {
+				return nil, fmt.Errorf("%w: cel %d, %d is both odd and even", ErrBadConstraints, cel.Row, cel.Col)
+			}
+			shaded[cel] = p.kind
+			var vals valSet
+			for val := MinVal; int(val) <= size; val++ {
+				if int(val)%2 == p.rem {
+					vals |= valBit(val)
+				}
+			}
+			built = append(built, &parity{Unit{p.kind, i}, celIndexes([]Cel{cel}, size), vals})
+		}
+	}
+
+	pairs, err := cs.buildPairs(size)
+	if err != nil {
+		return nil, err
+	}
+	return append(built, pairs...), nil
+}
+
+//  Check the constraints once the given values are loaded.  A broken one
+//  is reported with ErrConstraint, at its first cel.
+
+func (gp *grid) checkConstraints() error {
+
+	for _, c := range gp.geo.constraints {
+		if !c.holds(gp) {
+			cel := gp.geo.celOf(c.cels()[0])
+			return &CelError{Err: ErrConstraint, Row: cel.Row, Col: cel.Col, Unit: c.unit()}
+		}
+	}
+	return nil
+}
+
+// Options for the cel of a constraint, working them out again if a value
+// in the constraint has changed since
+
+func (gp *grid) constraintOptions(ref conRef) valSet {
+	if !gp.conFresh[ref.con] {
+		gp.geo.constraints[ref.con].options(gp, gp.conOpts[ref.con])
+		gp.conFresh[ref.con] = true
+	}
+	return gp.conOpts[ref.con][ref.pos]
+}
+
+// Mark the constraints covering a cel as needing their options worked out
+
+func (gp *grid) staleConstraints(idx int) {
+	if gp.conFresh != nil {
+		for _, ref := range gp.geo.celConstraints[idx] {
+			gp.conFresh[ref.con] = false
+		}
+	}
+}
+
+// Values from lo to hi inclusive, limited to the legal values
+func (gp *grid) valRange(lo, hi int) valSet {
+	if lo < int(MinVal) {
+		lo = int(MinVal)
+	}
+	if hi > int(gp.geo.maxVal) {
+		hi = int(gp.geo.maxVal)
+	}
+	if lo > hi {
+		return 0
+	}
+	return valSet(1<<uint(hi+1)-1) &^ valSet(1<<uint(lo)-1)
+}
+
+// Thermometer.  Each value is larger than the one before it, so a cel
+// n places after a placed value must be at least n more, and one n
+// places before it at least n less.  The ends of the line also leave
+// room for the cels beyond them.
+type thermo struct {
+	id   Unit
+	line []int // From the bulb
+}
+
+func (t *thermo) unit() Unit  { return t.id }
+func (t *thermo) cels() []int { return t.line }
+
+// Smallest and largest values for the cel at a position on the line
+func (t *thermo) bounds(gp *grid, pos int) (int, int) {
+	lo, hi := pos+1, int(gp.geo.maxVal)-(len(t.line)-1-pos)
+	for other, idx := range t.line {
+		val := int(gp.value[idx])
+		if val == int(Blank) || other == pos {
+			continue
+		}
+		if other < pos && val+pos-other > lo {
+			lo = val + pos - other
+		}
+		if other > pos && val-(other-pos) < hi {
+			hi = val - (other - pos)
+		}
+	}
+	return lo, hi
+}
+
+func (t *thermo) options(gp *grid, opts []valSet) {
+	for pos := range t.line {
+		opts[pos] = gp.valRange(t.bounds(gp, pos))
+	}
+}
+
+func (t *thermo) holds(gp *grid) bool {
+	for pos, idx := range t.line {
+		lo, hi := t.bounds(gp, pos)
+		if val := int(gp.value[idx]); lo > hi || (val != int(Blank) && (val < lo || val > hi)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Arrow.  The circle is the first cel, and the line the rest.  Each
+// blank cel on the line counts at least 1, so the circle is at least
+// the values placed on the line plus one for each blank, and a line cel
+// at most the circle, or the largest value, less the rest of the line.
+type arrowRule struct {
+	id  Unit
+	all []int // Circle then line
+}
+
+func (a *arrowRule) unit() Unit  { return a.id }
+func (a *arrowRule) cels() []int { return a.all }
+
+// Total of the values placed on the line, and the number of blanks
+func (a *arrowRule) line(gp *grid) (sum int, blanks int) {
+	for _, idx := range a.all[1:] {
+		if gp.value[idx] == Blank {
+			blanks++
+		}
+		sum += int(gp.value[idx])
+	}
+	return sum, blanks
+}
+
+func (a *arrowRule) options(gp *grid, opts []valSet) {
+
+	sum, blanks := a.line(gp)
+	max := int(gp.geo.maxVal)
+	opts[0] = gp.valRange(sum+blanks, sum+blanks*max)
+
+	// For a blank on the line, the others count between 1 and max each
+	others := blanks - 1
+	line := gp.valRange(1, max-sum-others)
+	if circle := int(gp.value[a.all[0]]); circle != int(Blank) {
+		line = gp.valRange(circle-sum-others*max, circle-sum-others)
+	}
+	for pos := 1; pos < len(a.all); pos++ {
+		opts[pos] = line
+	}
+}
+
+func (a *arrowRule) holds(gp *grid) bool {
+
+	sum, blanks := a.line(gp)
+	circle := int(gp.value[a.all[0]])
+	if circle == int(Blank) {
+		return sum+blanks <= int(gp.geo.maxVal)
+	}
+	return sum+blanks <= circle && circle <= sum+blanks*int(gp.geo.maxVal)
+}
+
+// Sandwich.  The line holds every value once, so the values between
+// the 1 and the largest are different ones from 2 to one less than the
+// largest, and none of those placed elsewhere on the line.  For each
+// pair of places the 1 and the largest might take, the total between
+// them ranges from the values placed there plus the smallest unused
+// values for the blanks, to the same with the largest.  A pair is
+// possible if the clue is in that range.
+//
+// A blank cel can take the 1 or the largest value if a possible pair
+// puts it there, and a middle value if a possible pair puts it outside
+// the sandwich, or still allows the clue with the value counted as
+// placed between.  This is the costliest constraint to work out, hence
+// the grid keeping the options of each constraint.
+type sandwich struct {
+	id   Unit
+	line []int
+	sum  int
+}
+
+func (s *sandwich) unit() Unit  { return s.id }
+func (s *sandwich) cels() []int { return s.line }
+
+// Values along the line, and the set of those placed
+func (s *sandwich) values(gp *grid) ([]CelVal, valSet) {
+	vals := make([]CelVal, len(s.line))
+	var placed valSet
+	for pos, idx := range s.line {
+		vals[pos] = gp.value[idx]
+		placed |= valBit(vals[pos])
+	}
+	return vals, placed
+}
+
+// True if the clue can be met with the 1 at position one and the
+// largest value at position top, given the values along the line
+func (s *sandwich) possible(vals []CelVal, placed valSet, maxVal CelVal, one, top int) bool {
+
+	if !(vals[one] == MinVal || (vals[one] == Blank && !placed.has(MinVal))) ||
+		!(vals[top] == maxVal || (vals[top] == Blank && !placed.has(maxVal))) {
+		return false
+	}
+
+	from, to := one, top
+	if from > to {
+		from, to = to, from
+	}
+	total, blanks := 0, 0
+	for _, v := range vals[from+1 : to] {
+		switch v {
+		case Blank:
+			blanks++
+		case MinVal, maxVal:
+			return false
+		default:
+			total += int(v)
+		}
+	}
+
+	// Smallest and largest totals of that many unused middle values
+	lo, hi := total, total
+	low, high := MinVal+1, maxVal-1
+	for n := 0; n < blanks; n++ {
+		for low < maxVal && placed.has(low) {
+			low++
+		}
+		for high > MinVal && placed.has(high) {
+			high--
+		}
+		if low >= maxVal || high <= MinVal {
+			return false
+		}
+		lo += int(low)
+		hi += int(high)
+		low++
+		high--
+	}
+	return lo <= s.sum && s.sum <= hi
+}
+
+func (s *sandwich) options(gp *grid, opts []valSet) {
+
+	vals, placed := s.values(gp)
+	maxVal := gp.geo.maxVal
+	middle := gp.geo.allVals &^ valBit(MinVal) &^ valBit(maxVal) &^ placed
+
+	for pos := range opts {
+		opts[pos] = 0
+	}
+	for one := range vals {
+		for top := range vals {
+			if one == top || !s.possible(vals, placed, maxVal, one, top) {
+				continue
+			}
+			opts[one] |= valBit(MinVal)
+			opts[top] |= valBit(maxVal)
+			for pos, val := range vals {
+				if pos == one || pos == top || val != Blank {
+					continue
+				}
+				if (pos < one) == (pos < top) {
+					opts[pos] |= middle
+					continue
+				}
+				for try := middle &^ opts[pos]; try != 0; try &^= valBit(try.first()) {
+					val := try.first()
+					vals[pos] = val
+					if s.possible(vals, placed|valBit(val), maxVal, one, top) {
+						opts[pos] |= valBit(val)
+					}
+				}
+				vals[pos] = Blank
+			}
+		}
+	}
+}
+
+func (s *sandwich) holds(gp *grid) bool {
+	vals, placed := s.values(gp)
+	for one := range vals {
+		for top := range vals {
+			if one != top && s.possible(vals, placed, gp.geo.maxVal, one, top) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Odd or even cel.  The values it can take never change.
+type parity struct {
+	id   Unit
+	cel  []int
+	vals valSet
+}
+
+func (p *parity) unit() Unit  { return p.id }
+func (p *parity) cels() []int { return p.cel }
+
+func (p *parity) options(gp *grid, opts []valSet) {
+	opts[0] = p.vals
+}
+
+func (p *parity) holds(gp *grid) bool {
+	val := gp.value[p.cel[0]]
+	return val == Blank || p.vals.has(val)
+}
@@ -0,0 +1,345 @@
+// "go test" program for line and shape constraints
+
+package sudoku
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// True if every thermometer, arrow and sandwich of a full grid holds
+func constraintsHold(sg *SizedGrid) bool {
+	val := func(cel Cel) int {
+		return int(sg.Cels[cel.Row][cel.Col])
+	}
+	for _, cels := range sg.Thermos {
+		for i := 1; i < len(cels); i++ {
+			if val(cels[i]) <= val(cels[i-1]) {
+				return false
+			}
+		}
+	}
+	for _, arrow := range sg.Arrows {
+		sum := 0
+		for _, cel := range arrow.Line {
+			sum += val(cel)
+		}
+		if sum != val(arrow.Circle) {
+			return false
+		}
+	}
+	for _, sw := range sg.Sandwiches {
+		if sandwichSum(sg, sw.Line) != sw.Sum {
+			return false
+		}
+	}
+	return true
+}
+
+// Total of the values between the 1 and the largest value of a row or
+// column of a full grid
+func sandwichSum(sg *SizedGrid, line Unit) int {
+	vals := make([]int, sg.Size())
+	for i := range vals {
+		if line.Kind == "row" {
+			vals[i] = int(sg.Cels[line.Index][i])
+		} else {
+			vals[i] = int(sg.Cels[i][line.Index])
+		}
+	}
+	a, b := -1, -1
+	for i, val := range vals {
+		if val == int(MinVal) {
+			a = i
+		}
+		if val == sg.Size() {
+			b = i
+		}
+	}
+	if a > b {
+		a, b = b, a
+	}
+	sum := 0
+	for i := a + 1; i < b; i++ {
+		sum += vals[i]
+	}
+	return sum
+}
+
+//  Constraints that hold in a solved grid: a thermometer over the first
+//  three cels of each row, lowest first, an arrow in each row whose line
+//  is the first pair of other cels adding up to the circle, and a
+//  sandwich on each row and column.
+
+func constraintsFrom(soln *SizedGrid) Constraints {
+
+	var cs Constraints
+	size := soln.Size()
+	for row := 0; row < size; row++ {
+		cels := []Cel{{row, 0}, {row, 1}, {row, 2}}
+		for i := 1; i < len(cels); i++ {
+			for j := i; j > 0 && soln.Cels[row][cels[j].Col] < soln.Cels[row][cels[j-1].Col]; j-- {
+				cels[j], cels[j-1] = cels[j-1], cels[j]
+			}
+		}
+		cs.Thermos = append(cs.Thermos, cels)
+	}
+
+	for row := 0; row < size; row++ {
+	search:
+		for circle := 0; circle < size; circle++ {
+			for a := 0; a < size; a++ {
+				for b := a + 1; b < size; b++ {
+					if a != circle && b != circle && soln.Cels[row][a]+soln.Cels[row][b] == soln.Cels[row][circle] {
+						cs.Arrows = append(cs.Arrows, Arrow{Cel{row, circle}, []Cel{{row, a}, {row, b}}})
+						break search
+					}
+				}
+			}
+		}
+	}
+
+	for _, kind := range []string{"row", "column"} {
+		for line := 0; line < size; line++ {
+			cs.Sandwiches = append(cs.Sandwiches, Sandwich{Unit{kind, line}, sandwichSum(soln, Unit{kind, line})})
+		}
+	}
+	return cs
+}
+
+//  Options the constraints leave in a classic grid, checked cel by cel as
+//  values are placed
+
+func TestConstraintOptions(t *testing.T) {
+
+	var gp grid
+	expect := func(what string, cel Cel, vals ...CelVal) {
+		var want valSet
+		for _, val := range vals {
+			want |= valBit(val)
+		}
+		if opts := gp.options(cel.Row*GridSize + cel.Col); opts != want {
+			t.Error(fmt.Sprintf("%s: cel %v has options %v.  Expected %v", what, cel, opts, want))
+		}
+	}
+	geoFor := func(cs Constraints) *geometry {
+		if err := (rules{constraints: cs}).check(GridSize); err != nil {
+			t.Fatal(fmt.Sprintf("Constraints not built.  Returned: %v", err))
+		}
+		return newGeometry(classic.shape, rules{constraints: cs})
+	}
+
+	// Each cel of a thermometer leaves room for those before and after it
+	gp.init(geoFor(Constraints{Thermos: [][]Cel{{{0, 0}, {0, 1}, {0, 2}}}}))
+	expect("thermo", Cel{0, 0}, 1, 2, 3, 4, 5, 6, 7)
+	expect("thermo", Cel{0, 1}, 2, 3, 4, 5, 6, 7, 8)
+	expect("thermo", Cel{0, 2}, 3, 4, 5, 6, 7, 8, 9)
+	gp.place(0, 5)
+	expect("thermo bulb 5", Cel{0, 1}, 6, 7, 8)
+	expect("thermo bulb 5", Cel{0, 2}, 7, 8, 9)
+
+	// Circle and line limit each other
+	gp.init(geoFor(Constraints{Arrows: []Arrow{{Cel{0, 0}, []Cel{{1, 1}, {2, 2}}}}}))
+	expect("arrow", Cel{0, 0}, 2, 3, 4, 5, 6, 7, 8, 9)
+	expect("arrow", Cel{1, 1}, 1, 2, 3, 4, 5, 6, 7, 8)
+	gp.place(0, 4)
+	expect("arrow circle 4", Cel{1, 1}, 1, 2, 3)
+	gp.place(10, 1)
+	expect("arrow circle 4", Cel{2, 2}, 3)
+
+	// The largest sum puts the 1 and 9 at the ends
+	gp.init(geoFor(Constraints{Sandwiches: []Sandwich{{Unit{"row", 0}, 35}}}))
+	expect("sandwich 35", Cel{0, 0}, 1, 9)
+	expect("sandwich 35", Cel{0, 4}, 2, 3, 4, 5, 6, 7, 8)
+	expect("sandwich 35", Cel{0, 8}, 1, 9)
+
+	// A 1 at the start and a sum of 2 leave a single order for the
+	// next two cels.  Cels outside the sandwich can take any middle value
+	gp.init(geoFor(Constraints{Sandwiches: []Sandwich{{Unit{"row", 0}, 2}}}))
+	gp.place(0, 1)
+	expect("sandwich 2", Cel{0, 1}, 2)
+	expect("sandwich 2", Cel{0, 2}, 9)
+	expect("sandwich 2", Cel{0, 5}, 2, 3, 4, 5, 6, 7, 8)
+}
+
+func TestConstraints(t *testing.T) {
+
+	var puzzle, soln Grid = hardGrid, hardGrid
+	Solve(&soln)
+	sizedSoln := SizedGridOf(&soln)
+	cs := constraintsFrom(&sizedSoln)
+
+	// Blank grids with each kind alone, and the hard puzzle with them all
+	blanks := map[string]Constraints{
+		"thermos":    {Thermos: cs.Thermos},
+		"arrows":     {Arrows: cs.Arrows},
+		"sandwiches": {Sandwiches: cs.Sandwiches},
+	}
+	for _, solver := range []Solver{Backtrack, DancingLinks} {
+		for name, blank := range blanks {
+			sg := NewSizedGrid(classic.shape)
+			sg.Constraints = blank
+			if err := SolveSizedWith(context.Background(), solver, &sg); err != nil || !solvedSized(&sg) || !constraintsHold(&sg) {
+				t.Error(fmt.Sprintf("%T: blank grid with %s not solved.  Returned: %v", solver, name, err))
+			}
+		}
+
+		sg := SizedGridOf(&puzzle)
+		sg.Constraints = cs
+		if n, err := CountSolutionsSizedWith(context.Background(), solver, &sg, 2); err != nil || n != 1 {
+			t.Error(fmt.Sprintf("%T: puzzle not unique.  Returned: %d, %v", solver, n, err))
+		}
+		if err := SolveSizedWith(context.Background(), solver, &sg); err != nil || fmt.Sprint(sg.Cels) != fmt.Sprint(sizedSoln.Cels) {
+			t.Error(fmt.Sprintf("%T: puzzle not solved.  Returned: %v", solver, err))
+		}
+	}
+
+	// Logic only uses eliminations the constraints allow
+	sg := SizedGridOf(&puzzle)
+	sg.Constraints = cs
+	checkSizedLogic(t, "constraints", &sg, &sizedSoln)
+
+	// Givens that break a constraint
+	sg = NewSizedGrid(classic.shape)
+	sg.Thermos = [][]Cel{{{0, 0}, {1, 1}}}
+	sg.Cels[0][0], sg.Cels[1][1] = 5, 3
+	var celErr *CelError
+	if err := SolveSized(&sg); !errors.As(err, &celErr) || celErr.Err != ErrConstraint || celErr.Unit != (Unit{"thermo", 0}) {
+		t.Error(fmt.Sprintf("Falling thermo.  Expected ErrConstraint.  Returned: %v", err))
+	}
+}
+
+func TestBadConstraints(t *testing.T) {
+
+	bad := map[string]Constraints{
+		"short thermo":   {Thermos: [][]Cel{{{0, 0}}}},
+		"long thermo":    {Thermos: [][]Cel{make([]Cel, GridSize+1)}},
+		"off grid":       {Thermos: [][]Cel{{{0, 0}, {0, GridSize}}}},
+		"repeated cel":   {Thermos: [][]Cel{{{0, 0}, {0, 1}, {0, 0}}}},
+		"empty arrow":    {Arrows: []Arrow{{Circle: Cel{0, 0}}}},
+		"circle on line": {Arrows: []Arrow{{Cel{0, 0}, []Cel{{0, 1}, {0, 0}}}}},
+		"sandwich box":   {Sandwiches: []Sandwich{{Unit{"box", 0}, 10}}},
+		"sandwich index": {Sandwiches: []Sandwich{{Unit{"row", GridSize}, 10}}},
+		"sandwich sum":   {Sandwiches: []Sandwich{{Unit{"column", 0}, 36}}},
+		"odd off grid":   {Odd: []Cel{{GridSize, 0}}},
+		"odd and even":   {Odd: []Cel{{0, 0}}, Even: []Cel{{1, 1}, {0, 0}}},
+	}
+	for name, cs := range bad {
+		sg := NewSizedGrid(classic.shape)
+		sg.Constraints = cs
+		if err := SolveSized(&sg); !errors.Is(err, ErrBadConstraints) {
+			t.Error(fmt.Sprintf("%s: expected ErrBadConstraints.  Returned: %v", name, err))
+		}
+	}
+}
+
+func TestJsolveConstraints(t *testing.T) {
+
+	var soln Grid = hardGrid
+	Solve(&soln)
+	sizedSoln := SizedGridOf(&soln)
+	cs := constraintsFrom(&sizedSoln)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"solution":   hardGrid,
+		"thermos":    cs.Thermos,
+		"arrows":     cs.Arrows,
+		"sandwiches": cs.Sandwiches,
+	})
+	var jGrid JsonGrid
+	if err := json.Unmarshal(body, &jGrid); err != nil || len(jGrid.Sandwiches) != 2*GridSize {
+		t.Fatal(fmt.Sprintf("Constraints not decoded.  Returned: %v", err))
+	}
+
+	Jsolve(&jGrid)
+	if jGrid.Code != "" || jGrid.Solution != soln {
+		t.Error(fmt.Sprintf("Jsolve with constraints failed.  Returned: %s", jGrid.Status))
+	}
+
+	jGrid.Sandwiches[0].Sum++
+	Jsolve(&jGrid)
+	if jGrid.Code != "constraint" {
+		t.Error(fmt.Sprintf("Failed to catch a wrong sandwich.  Returned: %s", jGrid.Status))
+	}
+
+	jGrid.Sandwiches[0].Line.Kind = "box"
+	Jsolve(&jGrid)
+	if jGrid.Code != "bad_constraints" {
+		t.Error(fmt.Sprintf("Failed to catch a bad sandwich.  Returned: %s", jGrid.Status))
+	}
+}
+
+// Every cel of a solved grid shaded odd or even
+func parityFrom(soln *SizedGrid) Constraints {
+	var cs Constraints
+	for row := range soln.Cels {
+		for col, val := range soln.Cels[row] {
+			if val%2 == 1 {
+				cs.Odd = append(cs.Odd, Cel{row, col})
+			} else {
+				cs.Even = append(cs.Even, Cel{row, col})
+			}
+		}
+	}
+	return cs
+}
+
+func TestParity(t *testing.T) {
+
+	var gp grid
+	cs := Constraints{Odd: []Cel{{0, 0}}, Even: []Cel{{0, 1}}}
+	if err := (rules{constraints: cs}).check(GridSize); err != nil {
+		t.Fatal(fmt.Sprintf("Shading not built.  Returned: %v", err))
+	}
+	gp.init(newGeometry(classic.shape, rules{constraints: cs}))
+	if opts := gp.options(0); opts != valBit(1)|valBit(3)|valBit(5)|valBit(7)|valBit(9) {
+		t.Error(fmt.Sprintf("Odd cel has options %v", opts))
+	}
+	if opts := gp.options(1); opts != valBit(2)|valBit(4)|valBit(6)|valBit(8) {
+		t.Error(fmt.Sprintf("Even cel has options %v", opts))
+	}
+
+	var puzzle, soln Grid = hardGrid, hardGrid
+	Solve(&soln)
+	sizedSoln := SizedGridOf(&soln)
+	cs = parityFrom(&sizedSoln)
+	for _, solver := range []Solver{Backtrack, DancingLinks} {
+		sg := SizedGridOf(&puzzle)
+		sg.Constraints = cs
+		if err := SolveSizedWith(context.Background(), solver, &sg); err != nil || fmt.Sprint(sg.Cels) != fmt.Sprint(sizedSoln.Cels) {
+			t.Error(fmt.Sprintf("%T: shaded puzzle not solved.  Returned: %v", solver, err))
+		}
+	}
+	sg := SizedGridOf(&puzzle)
+	sg.Constraints = cs
+	checkSizedLogic(t, "parity", &sg, &sizedSoln)
+
+	// An even value in an odd cel
+	sg = NewSizedGrid(classic.shape)
+	sg.Odd = []Cel{{4, 4}}
+	sg.Cels[4][4] = 6
+	var celErr *CelError
+	if err := SolveSized(&sg); !errors.As(err, &celErr) || celErr.Err != ErrConstraint || celErr.Unit != (Unit{"odd", 0}) {
+		t.Error(fmt.Sprintf("Odd cel broken.  Expected ErrConstraint.  Returned: %v", err))
+	}
+
+	// Sent in JSON, and used by the candidates service
+	var jGrid JsonGrid
+	body, _ := json.Marshal(map[string]interface{}{"solution": hardGrid, "odd": cs.Odd, "even": cs.Even})
+	if err := json.Unmarshal(body, &jGrid); err != nil || len(jGrid.Odd)+len(jGrid.Even) != classic.numCels {
+		t.Fatal(fmt.Sprintf("Shading not decoded.  Returned: %v", err))
+	}
+	Jsolve(&jGrid)
+	if jGrid.Code != "" || jGrid.Solution != soln {
+		t.Error(fmt.Sprintf("Jsolve with shading failed.  Returned: %s", jGrid.Status))
+	}
+	var jCands JsonCandidates
+	jCands.Even = []Cel{{0, 0}}
+	Jcandidates(&jCands)
+	if jCands.Code != "" || fmt.Sprint(jCands.Candidates[0][0]) != "[2 4 6 8]" {
+		t.Error(fmt.Sprintf("Even cel not in the candidates.  Returned: %v, %s", jCands.Candidates[0][0], jCands.Status))
+	}
+}
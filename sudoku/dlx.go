@@ -0,0 +1,376 @@
+//
+// Copyright 2020, 2021 Kenneth J. Gibson
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//
+// Dancing Links solver backend.
+// Solves a puzzle as an exact cover problem using Knuth's Algorithm X.
+// Each matrix column is a constraint that must be met exactly once:
+//	Cel:		each cel holds one value
+//	Unit/value:	each unit holds each value once
+// Each matrix row is one candidate value for one cel, and covers one cel
+// column plus one unit/value column for each unit the cel is in.
+//
+// A unit with fewer cels than values, such as a killer cage, only needs
+// each value at most once, so its unit/value columns are secondary: they
+// are left out of the header list, and only stop two rows using the same
+// one.  Cage sums, the chess variants and the line and shape constraints
+// are not part of the matrix.  Instead the chosen rows are placed in the
+// grid as the search goes, and a row is skipped if the grid says the
+// value would break one of them.
+//
+// Written independently of the backtracking search so the two engines
+// can be cross-checked against each other.
+//
+
+package sudoku
+
+import (
+	"context"
+)
+
+//
+//  The sparse matrix is held in parallel slices indexed by node number
+//  rather than as linked structs, so building it is a handful of
+//  allocations and the search itself allocates nothing.
+//  Node 0 is the root, nodes 1 to cols are the column headers and the
+//  matrix entries follow.
+//
+type dlx struct {
+	left, right []int // Row links, or header list links for the headers
+	up, down    []int // Column links
+	colOf       []int // Header node for each node
+	rowOf       []int // Matrix row for each entry node
+
+	cols   int      // Number of exact cover constraints (matrix columns)
+	size   []int    // Entries remaining in each column, by header node
+	rowCel []int    // Cel for each matrix row
+	rowVal []CelVal // Value for each matrix row
+
+	base  *grid // Prepared grid, with the rows on the search path placed in it
+	nodes int   // Search nodes visited.  Used to poll the context
+}
+
+// Header node for the constraint that a unit holds the value
+func (d *dlx) unitValHeader(unit int, val CelVal) int {
+	geo := d.base.geo
+	return 1 + geo.numCels + unit*geo.size + int(val-MinVal)
+}
+
+// Append a node and return its number.  Links point to itself
+func (d *dlx) newNode(col int) int {
+	node := len(d.left)
+	d.left = append(d.left, node)
+	d.right = append(d.right, node)
+	d.up = append(d.up, node)
+	d.down = append(d.down, node)
+	d.colOf = append(d.colOf, col)
+	d.rowOf = append(d.rowOf, -1)
+	return node
+}
+
+//  Build the exact cover matrix for the blank cels of a prepared grid.
+//  Constraints already met by filled cels are left out of the header
+//  list, and only values legal for a cel get a matrix row, so no row
+//  can touch a constraint that is already met.
+
+func newDLX(gp *grid) *dlx {
+
+	geo := gp.geo
+	d := &dlx{base: gp, cols: geo.numCels + len(geo.units)*geo.size}
+	d.size = make([]int, d.cols+1)
+
+	// Root and column headers
+	for col := 0; col <= d.cols; col++ {
+		d.newNode(col)
+	}
+
+	// Link the headers of the open constraints in a circular list
+	linkHeader := func(h int) {
+		d.left[h] = d.left[0]
+		d.right[h] = 0
+		d.right[d.left[0]] = h
+		d.left[0] = h
+	}
+	for idx := 0; idx < geo.numCels; idx++ {
+		if gp.value[idx] == Blank {
+			linkHeader(1 + idx)
+		}
+	}
+	for unit := range geo.unitCels {
+		if !geo.full(unit) {
+			// Secondary.  See above
+			continue
+		}
+		for val := MinVal; val <= geo.maxVal; val++ {
+			if !gp.used[unit].has(val) {
+				linkHeader(d.unitValHeader(unit, val))
+			}
+		}
+	}
+
+	// One row per candidate value of each blank cel
+	for idx := 0; idx < geo.numCels; idx++ {
+		if gp.value[idx] != Blank {
+			continue
+		}
+		for opts := gp.options(idx); opts != 0; opts &^= valBit(opts.first()) {
+			val := opts.first()
+			row := len(d.rowCel)
+			d.rowCel = append(d.rowCel, idx)
+			d.rowVal = append(d.rowVal, val)
+
+			first := d.addEntry(1+idx, row, -1)
+			for _, unit := range geo.celUnits[idx] {
+				d.addEntry(d.unitValHeader(unit, val), row, first)
+			}
+		}
+	}
+	return d
+}
+
+// Add a matrix entry at the bottom of a column, and at the end of the
+// row starting at node first.  Pass first as -1 to start a new row.
+// Returns the new node.
+
+func (d *dlx) addEntry(h int, row int, first int) int {
+
+	node := d.newNode(h)
+	d.rowOf[node] = row
+
+	d.up[node] = d.up[h]
+	d.down[node] = h
+	d.down[d.up[h]] = node
+	d.up[h] = node
+	d.size[h]++
+
+	if first >= 0 {
+		d.left[node] = d.left[first]
+		d.right[node] = first
+		d.right[d.left[first]] = node
+		d.left[first] = node
+	}
+	return node
+}
+
+// Remove a column from the header list, and every row that meets the
+// column from the other columns it is in
+
+func (d *dlx) cover(h int) {
+
+	d.right[d.left[h]] = d.right[h]
+	d.left[d.right[h]] = d.left[h]
+
+	for i := d.down[h]; i != h; i = d.down[i] {
+		for j := d.right[i]; j != i; j = d.right[j] {
+			d.down[d.up[j]] = d.down[j]
+			d.up[d.down[j]] = d.up[j]
+			d.size[d.colOf[j]]--
+		}
+	}
+}
+
+// Exact reverse of cover.  Must be called in the reverse order
+
+func (d *dlx) uncover(h int) {
+
+	for i := d.up[h]; i != h; i = d.up[i] {
+		for j := d.left[i]; j != i; j = d.left[j] {
+			d.size[d.colOf[j]]++
+			d.down[d.up[j]] = j
+			d.up[d.down[j]] = j
+		}
+	}
+
+	d.right[d.left[h]] = h
+	d.left[d.right[h]] = h
+}
+
+//  Rows left that could meet a constraint.  With rules outside the
+//  matrix, some rows of a cel would break them, so the cel's options are
+//  counted instead.
+
+func (d *dlx) rowsLeft(h int) int {
+	if d.base.geo.outsideMatrix() && h <= d.base.geo.numCels {
+		return d.base.options(h - 1).count()
+	}
+	return d.size[h]
+}
+
+//  Algorithm X.  Picks the open constraint with the fewest rows left,
+//  then tries each row that meets it.  Calls visit when every constraint
+//  is met, with the chosen rows placed in the base grid.
+//
+//  Returns false if the search was stopped, either by visit or because the
+//  context was canceled or timed out.  The error reports the latter.
+
+func (d *dlx) search(ctx context.Context, visit func() bool) (bool, error) {
+
+	d.nodes++
+	if d.nodes%ctxPollInterval == 0 {
+		if err := ctxError(ctx); err != nil {
+			return false, err
+		}
+	}
+
+	if d.right[0] == 0 {
+		// Every constraint met
+		return visit(), nil
+	}
+
+	minH, minSize := 0, 0
+	for h := d.right[0]; h != 0; h = d.right[h] {
+		if size := d.rowsLeft(h); minH == 0 || size < minSize {
+			minH, minSize = h, size
+		}
+	}
+	if minSize == 0 {
+		// Dead end
+		return true, nil
+	}
+
+	d.cover(minH)
+	for r := d.down[minH]; r != minH; r = d.down[r] {
+		idx, val := d.rowCel[d.rowOf[r]], d.rowVal[d.rowOf[r]]
+		if d.base.geo.outsideMatrix() && !d.base.allows(idx, val) {
+			continue
+		}
+
+		d.base.place(idx, val)
+		for j := d.right[r]; j != r; j = d.right[j] {
+			d.cover(d.colOf[j])
+		}
+
+		more, err := d.search(ctx, visit)
+
+		for j := d.left[r]; j != r; j = d.left[j] {
+			d.uncover(d.colOf[j])
+		}
+		d.base.remove(idx)
+
+		if !more {
+			d.uncover(minH)
+			return false, err
+		}
+	}
+	d.uncover(minH)
+	return true, nil
+}
+
+// True if the geometry has rules the exact cover matrix leaves out
+func (geo *geometry) outsideMatrix() bool {
+	return geo.sums != nil || geo.moves != nil || geo.constraints != nil
+}
+
+//  True if the value can go in the cel without breaking a cage sum, a
+//  chess variant or a constraint, and leaves every other blank cel of
+//  the cel's cages and constraints, and every blank cel a move away, with
+//  an option
+
+func (gp *grid) allows(idx int, val CelVal) bool {
+
+	if !gp.options(idx).has(val) {
+		return false
+	}
+
+	gp.place(idx, val)
+	defer gp.remove(idx)
+
+	blocked := func(cels []int) bool {
+		for _, other := range cels {
+			if gp.value[other] == Blank && gp.options(other) == 0 {
+				return true
+			}
+		}
+		return false
+	}
+	for _, rule := range gp.geo.moves {
+		if blocked(rule.cels[idx]) {
+			return false
+		}
+	}
+	if gp.geo.celConstraints != nil {
+		for _, ref := range gp.geo.celConstraints[idx] {
+			c := gp.geo.constraints[ref.con]
+			if blocked(c.cels()) {
+				return false
+			}
+		}
+	}
+	for _, unit := range gp.geo.celUnits[idx] {
+		if gp.geo.sums != nil && gp.geo.sums[unit] != 0 && blocked(gp.geo.unitCels[unit]) {
+			return false
+		}
+	}
+	return true
+}
+
+//  The Dancing Links backend.  Uses the same front end as the backtracking
+//  engine so invalid puzzles are reported the same way.
+
+type dlxSolver struct{}
+
+func (s dlxSolver) Solutions(ctx context.Context, configP *Grid, limit int, fn func(soln Grid) bool) (int, error) {
+
+	count := 0
+	err := s.search(ctx, configP, func(gp *grid) bool {
+		var soln Grid
+		gp.store(&soln)
+		count++
+		return fn(soln) && (limit <= 0 || count < limit)
+	})
+	return count, err
+}
+
+func (s dlxSolver) SizedSolutions(ctx context.Context, configP *SizedGrid, limit int, fn func(soln SizedGrid) bool) (int, error) {
+
+	count := 0
+	err := s.search(ctx, configP, func(gp *grid) bool {
+		soln := configP.blank()
+		gp.store(&soln)
+		count++
+		return fn(soln) && (limit <= 0 || count < limit)
+	})
+	return count, err
+}
+
+func (s dlxSolver) MultiSolutions(ctx context.Context, configP *MultiGrid, limit int, fn func(soln MultiGrid) bool) (int, error) {
+
+	count := 0
+	err := s.search(ctx, configP, func(gp *grid) bool {
+		soln := configP.blank()
+		gp.store(&soln)
+		count++
+		return fn(soln) && (limit <= 0 || count < limit)
+	})
+	return count, err
+}
+
+//  Search shared by both entry points.  Calls visit with each solution
+
+func (dlxSolver) search(ctx context.Context, configP celGrid, visit func(gp *grid) bool) error {
+
+	var gp grid
+
+	if _, err := gp.prepare(ctx, configP); err != nil {
+		return err
+	}
+
+	d := newDLX(&gp)
+	_, err := d.search(ctx, func() bool {
+		return visit(&gp)
+	})
+	return err
+}
@@ -0,0 +1,106 @@
+// "go test" program to cross-check the Dancing Links backend against
+// the backtracking engine
+
+package sudoku
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestDLXCrossCheck(t *testing.T) {
+
+	puzzles := append([]Grid{easyGrid, medGrid, hardGrid}, clue17Grids(t)...)
+
+	for i, puzzle := range puzzles {
+		btGrid, dlxGrid := puzzle, puzzle
+
+		btErr := SolveWith(context.Background(), Backtrack, &btGrid)
+		dlxErr := SolveWith(context.Background(), DancingLinks, &dlxGrid)
+		if btErr != nil || dlxErr != nil {
+			t.Error(fmt.Sprintf("Puzzle %d failed.  Returned: %v, %v", i, btErr, dlxErr))
+			continue
+		}
+		if btGrid != dlxGrid {
+			t.Error(fmt.Sprintf("Puzzle %d solutions differ", i))
+			printGrid(&btGrid)
+			printGrid(&dlxGrid)
+		}
+	}
+}
+
+func TestDLXCount(t *testing.T) {
+
+	var multiGrid Grid = hardGrid
+	multiGrid[0][0] = Blank
+	multiGrid[0][2] = Blank
+
+	btCount, _ := CountSolutionsWith(context.Background(), Backtrack, &multiGrid, 0)
+	dlxCount, err := CountSolutionsWith(context.Background(), DancingLinks, &multiGrid, 0)
+	if err != nil || btCount != dlxCount {
+		errString := fmt.Sprintf("Solution counts differ.  Returned: %d, %d, %v", btCount, dlxCount, err)
+		t.Error(errString)
+	} else {
+		fmt.Printf("\nBoth backends counted %d solutions\n", dlxCount)
+	}
+
+	var blankGrid Grid
+	if count, err := CountSolutionsWith(context.Background(), DancingLinks, &blankGrid, 10); err != nil || count != 10 {
+		t.Error(fmt.Sprintf("Failed blank grid count.  Returned: %d, %v", count, err))
+	}
+}
+
+func TestDLXErrors(t *testing.T) {
+
+	for _, puzzle := range []Grid{ooRangeGrid, illegalGrid} {
+		btGrid, dlxGrid := puzzle, puzzle
+
+		btErr := SolveWith(context.Background(), Backtrack, &btGrid)
+		dlxErr := SolveWith(context.Background(), DancingLinks, &dlxGrid)
+		if dlxErr == nil || btErr == nil || dlxErr.Error() != btErr.Error() {
+			t.Error(fmt.Sprintf("Errors differ.  Returned: %v, %v", btErr, dlxErr))
+		}
+	}
+
+	// Legal starting config, but no way to complete it
+	var noSolnGrid Grid = hardGrid
+	noSolnGrid[8][8] = 2
+	if err := SolveWith(context.Background(), DancingLinks, &noSolnGrid); err == nil {
+		t.Error("Failed to catch unsolvable puzzle")
+	} else {
+		fmt.Printf("Caught unsolvable puzzle: %v\n", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var testGrid Grid = hardGrid
+	if err := SolveWith(ctx, DancingLinks, &testGrid); err != ErrCanceled {
+		t.Error(fmt.Sprintf("Failed to cancel.  Returned: %v", err))
+	}
+
+	var jGrid JsonGrid
+	jGrid.Solution = hardGrid
+	jGrid.Solver = "nosuch"
+	Jsolve(&jGrid)
+	if jGrid.Status == "Success" {
+		t.Error("Failed to catch unknown solver")
+	}
+}
+
+//  Solves the whole corpus once per iteration
+func BenchmarkDLX17Clue(b *testing.B) {
+
+	corpus := clue17Grids(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, puzzle := range corpus {
+			testGrid := puzzle
+			if err := SolveWith(context.Background(), DancingLinks, &testGrid); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+//
+// Copyright 2020, 2021 Kenneth J. Gibson
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//
+// Errors returned by the sudoku engine.
+// Each failure has a sentinel error for its category, for use with
+// errors.Is.  Failures tied to a cel are returned as a *CelError that
+// wraps the sentinel and carries the location, for use with errors.As.
+//
+
+package sudoku
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors for each category of failure
+var ErrOutOfRange = errors.New("illegal value")                     // Cel value above the grid size
+var ErrConflict = errors.New("illegal config.  Duplicate value")    // Value repeated in a unit
+var ErrNoCandidates = errors.New("illegal config.  No legal value") // Blank cel with no legal value
+var ErrUnsolvable = errors.New("No solution found.")                // Legal config but no solution
+var ErrTimeout = errors.New("solve timed out")
+var ErrCanceled = errors.New("solve canceled")
+var ErrUnknownSolver = errors.New("unknown solver")
+var ErrNoPuzzle = errors.New("no puzzle found for the options") // Generator gave up
+var ErrSolved = errors.New("puzzle already solved")             // Nothing left to hint
+var ErrNotUnique = errors.New("puzzle has more than one solution")
+var ErrGivenChanged = errors.New("entry differs from given") // Player entry over a given cel
+var ErrBadSize = errors.New("unsupported grid size")
+var ErrBadRegions = errors.New("malformed region map") // Jigsaw regions that cannot tile the grid
+var ErrBadCages = errors.New("malformed cages")        // Killer cages that overlap or cannot make their sums
+var ErrCageSum = errors.New("illegal config.  Cage sum not met")
+var ErrBadConstraints = errors.New("malformed constraints") // Thermometers, arrows or sandwiches that do not fit the grid
+var ErrConstraint = errors.New("illegal config.  Constraint not met")
+var ErrBadLayout = errors.New("unsupported multi-grid layout")
+var ErrBadFormat = errors.New("malformed puzzle string") // Not 81 digits and dots.  See ParseString
+
+// Identifies a unit of the grid.  Index counts from 0, left to right
+// and top to bottom.
+type Unit struct {
+	Kind  string `json:"kind"` // "row", "column", "box", or a variant region such as "diagonal"
+	Index int    `json:"index"`
+}
+
+func (u Unit) String() string {
+	return fmt.Sprintf("%s %d", u.Kind, u.Index)
+}
+
+// Error for a failure at a specific cel.  Unit is the unit holding
+// the conflicting value for ErrConflict, or the cage or constraint that
+// is broken for ErrCageSum or ErrConstraint.  Otherwise it is not set.
+type CelError struct {
+	Err  error // Category of the failure
+	Row  int
+	Col  int
+	Unit Unit
+}
+
+func (e *CelError) Error() string {
+	if e.Unit.Kind == "" {
+		return fmt.Sprintf("%v for cel %d, %d", e.Err, e.Row, e.Col)
+	}
+	return fmt.Sprintf("%v for cel %d, %d in %v", e.Err, e.Row, e.Col, e.Unit)
+}
+
+func (e *CelError) Unwrap() error {
+	return e.Err
+}
+
+// Machine-readable code for an error returned by the engine, for
+// clients that cannot use errors.Is.  Returns "" for a nil error.
+
+func ErrorCode(err error) string {
+
+	codes := []struct {
+		err  error
+		code string
+	}{
+		{ErrOutOfRange, "out_of_range"},
+		{ErrConflict, "conflict"},
+		{ErrNoCandidates, "no_candidates"},
+		{ErrUnsolvable, "unsolvable"},
+		{ErrTimeout, "timeout"},
+		{ErrCanceled, "canceled"},
+		{ErrUnknownSolver, "unknown_solver"},
+		{ErrNoPuzzle, "no_puzzle"},
+		{ErrSolved, "solved"},
+		{ErrNotUnique, "not_unique"},
+		{ErrGivenChanged, "given_changed"},
+		{ErrBadSize, "bad_size"},
+		{ErrBadRegions, "bad_regions"},
+		{ErrBadCages, "bad_cages"},
+		{ErrCageSum, "cage_sum"},
+		{ErrBadConstraints, "bad_constraints"},
+		{ErrConstraint, "constraint"},
+		{ErrBadLayout, "bad_layout"},
+		{ErrBadFormat, "bad_format"},
+	}
+
+	if err == nil {
+		return ""
+	}
+	for _, c := range codes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+	return "error"
+}
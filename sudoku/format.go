@@ -0,0 +1,81 @@
+//
+// Copyright 2020, 2021 Kenneth J. Gibson
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//
+// One-line puzzle format.
+// Most published puzzle collections give each standard grid as a single
+// line of 81 characters, row by row from the top left, with a digit for
+// each given and a '.' or '0' for each blank cel, e.g.
+//	3.5.71..9...34.....9.2......3...4....6......7.....285........8..54...9.1..7...4..
+// ParseString reads a line into a Grid, and Grid.String writes one back
+// out, with '.' for the blanks.
+//
+
+package sudoku
+
+import (
+	"fmt"
+	"strings"
+)
+
+//  Read a standard grid from the one-line format.  Whitespace around the
+//  line is ignored.  Returns ErrBadFormat if the line is not 81 cels
+//  long, or as a CelError at the first character that is not a digit or
+//  a '.'.
+
+func ParseString(s string) (Grid, error) {
+
+	var config Grid
+
+	line := strings.TrimSpace(s)
+	if len(line) != GridSize*GridSize {
+		return config, fmt.Errorf("%w: %d characters, not %d", ErrBadFormat, len(line), GridSize*GridSize)
+	}
+
+	for i, ch := range []byte(line) {
+		row, col := i/GridSize, i%GridSize
+		switch {
+		case ch == '.' || ch == '0':
+			config[row][col] = Blank
+		case ch >= '1' && ch <= '9':
+			config[row][col] = CelVal(ch - '0')
+		default:
+			return config, &CelError{Err: ErrBadFormat, Row: row, Col: col}
+		}
+	}
+	return config, nil
+}
+
+//  The grid in the one-line format, with '.' for a blank cel.  A value
+//  out of range is written as '?', so it cannot be read back.
+
+func (configP *Grid) String() string {
+
+	var b strings.Builder
+	for _, row := range configP {
+		for _, val := range row {
+			switch {
+			case val == Blank:
+				b.WriteByte('.')
+			case val.IsValid():
+				b.WriteByte('0' + byte(val))
+			default:
+				b.WriteByte('?')
+			}
+		}
+	}
+	return b.String()
+}
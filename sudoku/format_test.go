@@ -0,0 +1,63 @@
+// "go test" program for the one-line puzzle format
+
+package sudoku
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// The hard puzzle as one line
+const hardLine = "3.5.71..9...34.....9.2......3...4....6......7.....285........8..54...9.1..7...4.."
+
+func TestParseString(t *testing.T) {
+
+	var hard Grid = hardGrid
+	for name, line := range map[string]string{
+		"dots":       hardLine,
+		"zeros":      strings.ReplaceAll(hardLine, ".", "0"),
+		"whitespace": "  " + hardLine + "\r\n",
+	} {
+		if config, err := ParseString(line); err != nil || config != hard {
+			t.Error(fmt.Sprintf("%s: puzzle not read.  Returned: %v", name, err))
+		}
+	}
+
+	for name, line := range map[string]string{
+		"empty": "",
+		"short": hardLine[1:],
+		"long":  hardLine + ".",
+		"space": hardLine[:40] + " " + hardLine[41:],
+	} {
+		if _, err := ParseString(line); !errors.Is(err, ErrBadFormat) || ErrorCode(err) != "bad_format" {
+			t.Error(fmt.Sprintf("%s: expected ErrBadFormat.  Returned: %v", name, err))
+		}
+	}
+
+	// A bad character is reported at its cel
+	var celErr *CelError
+	if _, err := ParseString(hardLine[:12] + "x" + hardLine[13:]); !errors.As(err, &celErr) || celErr.Row != 1 || celErr.Col != 3 {
+		t.Error(fmt.Sprintf("Bad character not placed.  Returned: %v", err))
+	}
+}
+
+func TestGridString(t *testing.T) {
+
+	var config Grid = hardGrid
+	if line := config.String(); line != hardLine {
+		t.Error(fmt.Sprintf("Wrong line.  Returned: %s", line))
+	}
+
+	// A solved grid reads back the same
+	Solve(&config)
+	if again, err := ParseString(config.String()); err != nil || again != config || strings.Contains(config.String(), ".") {
+		t.Error(fmt.Sprintf("Solved grid not read back.  Returned: %v", err))
+	}
+
+	config[0][0] = 10
+	if line := config.String(); line[0] != '?' {
+		t.Error(fmt.Sprintf("Out of range value written.  Returned: %s", line))
+	}
+}
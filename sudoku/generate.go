@@ -0,0 +1,342 @@
+//
+// Copyright 2020, 2021 Kenneth J. Gibson
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//
+// Puzzle generator.
+// Fills a blank grid at random, then removes clues in random order,
+// putting back any removal that leaves more than one solution.  Clues
+// are removed in symmetric groups when a symmetry is asked for.
+// Everything is drawn from a generator seeded by the caller, so the
+// same options always give the same puzzle.
+//
+// When a difficulty band is asked for, removals that push the puzzle
+// above the band are also put back.  A puzzle that ends below the band
+// is thrown away and another one is tried.
+//
+// Variant rules are kept by the random fill and by every check of the
+// puzzle, so a Sudoku X puzzle, for example, is only unique under the
+// diagonal rule.  An odd/even puzzle shades every cel of the filled grid
+// as odd or even before any clue is removed, so the shading does the
+// work of many clues.
+//
+
+package sudoku
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+)
+
+// Symmetry of the clue layout of a generated puzzle
+type Symmetry int
+
+const (
+	NoSymmetry Symmetry = iota
+	Rotational          // Unchanged by a half turn
+	Mirror              // Unchanged by a left-right reflection
+	Diagonal            // Unchanged by a reflection in the main diagonal
+	numSymmetries
+)
+
+var symmetryNames = [numSymmetries]string{"none", "rotational", "mirror", "diagonal"}
+
+func (s Symmetry) String() string {
+	if s < 0 || s >= numSymmetries {
+		return fmt.Sprintf("Symmetry(%d)", int(s))
+	}
+	return symmetryNames[s]
+}
+
+// Symmetries are sent by name in JSON
+func (s Symmetry) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Symmetry) UnmarshalText(text []byte) error {
+	for i, name := range symmetryNames {
+		if string(text) == name {
+			*s = Symmetry(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown symmetry %q", text)
+}
+
+// Number of complete grids tried before Generate gives up on the options
+const maxGenerateAttempts = 100
+
+// Options for Generate
+type GenerateOptions struct {
+	Seed       int64      // Seed for the random source
+	Clues      int        // Stop removing clues at this count.  0 removes as many as possible
+	Symmetry   Symmetry   // Layout of the clues
+	Difficulty Difficulty // Band wanted, or AnyDifficulty
+	Variants   Variants   // Extra rules the puzzle must follow
+	Parity     bool       // Shade every cel as odd or even
+}
+
+// A generated puzzle with its unique solution and grade
+type Generated struct {
+	Puzzle      Grid
+	Solution    Grid
+	Clues       int
+	Grade       GradeResult
+	Constraints Constraints // The odd and even cels if Parity was asked for
+}
+
+//  Fill the blank cels of the grid with a random legal solution.
+//  Tries the options of each cel in random order, starting with the
+//  cel that has the fewest.  Returns false if there is no solution, or
+//  if the context was canceled or timed out, which the error reports.
+
+func (gp *grid) randomFill(ctx context.Context, r *rand.Rand) (bool, error) {
+
+	gp.nodes++
+	if gp.nodes%ctxPollInterval == 0 {
+		if err := ctxError(ctx); err != nil {
+			return false, err
+		}
+	}
+
+	idx, opts := gp.findMinOptionCel()
+	if idx < 0 {
+		return true, nil
+	}
+
+	var vals []CelVal
+	for ; opts != 0; opts &^= valBit(opts.first()) {
+		vals = append(vals, opts.first())
+	}
+	r.Shuffle(len(vals), func(i, j int) { vals[i], vals[j] = vals[j], vals[i] })
+
+	for _, val := range vals {
+		gp.place(idx, val)
+		if filled, err := gp.randomFill(ctx, r); filled || err != nil {
+			return filled, err
+		}
+		gp.remove(idx)
+	}
+	return false, nil
+}
+
+//  Cels that must be cleared together with idx to keep the symmetry,
+//  including idx itself
+
+func symmetricCels(idx int, sym Symmetry) []int {
+
+	row, col := idx/GridSize, idx%GridSize
+	var other int
+
+	switch sym {
+	case Rotational:
+		other = classic.numCels - 1 - idx
+	case Mirror:
+		other = row*GridSize + GridSize - 1 - col
+	case Diagonal:
+		other = col*GridSize + row
+	default:
+		other = idx
+	}
+
+	if other == idx {
+		return []int{idx}
+	}
+	return []int{idx, other}
+}
+
+//  Build one puzzle from a fresh random grid
+
+func generateOnce(ctx context.Context, r *rand.Rand, opts GenerateOptions) (Generated, error) {
+
+	// Standard shape, so cannot fail
+	geo, _ := geometryOf(classic.shape, rules{variants: opts.Variants})
+
+	var gen Generated
+	var full grid
+	full.init(geo)
+	filled, err := full.randomFill(ctx, r)
+	if err != nil {
+		return Generated{}, err
+	}
+	if !filled {
+		// No grid meets the rules, so no other attempt will find one
+		return Generated{}, fmt.Errorf("%w: no grid meets the variant rules", ErrNoPuzzle)
+	}
+	full.store(&gen.Solution)
+
+	if opts.Parity {
+		for idx, val := range full.value {
+			if val%2 == 1 {
+				gen.Constraints.Odd = append(gen.Constraints.Odd, classic.celOf(idx))
+			} else {
+				gen.Constraints.Even = append(gen.Constraints.Even, classic.celOf(idx))
+			}
+		}
+	}
+
+	puzzle := gen.Solution
+	clues := classic.numCels
+
+	// The puzzle with the variant rules and shading, for checking it
+	withRules := func() *SizedGrid {
+		sg := SizedGridOf(&puzzle)
+		sg.Variants, sg.Constraints = opts.Variants, gen.Constraints
+		return &sg
+	}
+
+	for _, idx := range r.Perm(classic.numCels) {
+		if puzzle[idx/GridSize][idx%GridSize] == Blank {
+			// Already cleared along with a symmetric partner
+			continue
+		}
+		group := symmetricCels(idx, opts.Symmetry)
+		if clues-len(group) < opts.Clues {
+			continue
+		}
+
+		for _, i := range group {
+			puzzle[i/GridSize][i%GridSize] = Blank
+		}
+
+		n, err := CountSolutionsSizedWith(ctx, Backtrack, withRules(), 2)
+		if err != nil {
+			return Generated{}, err
+		}
+		keep := n == 1
+		if keep && opts.Difficulty != AnyDifficulty {
+			grade, err := gradeGrid(ctx, withRules())
+			if err != nil {
+				return Generated{}, err
+			}
+			keep = grade.Difficulty <= opts.Difficulty
+		}
+
+		if !keep {
+			for _, i := range group {
+				puzzle[i/GridSize][i%GridSize] = gen.Solution[i/GridSize][i%GridSize]
+			}
+			continue
+		}
+		clues -= len(group)
+	}
+
+	grade, err := gradeGrid(ctx, withRules())
+	if err != nil {
+		return Generated{}, err
+	}
+	gen.Puzzle, gen.Clues, gen.Grade = puzzle, clues, grade
+	return gen, nil
+}
+
+//  Public entry point for generating a puzzle.
+//  Returns a puzzle with exactly one solution that meets the options.
+//  Returns ErrOutOfRange for an invalid option, or ErrNoPuzzle if no
+//  puzzle in the wanted band turned up or no grid meets the variant rules.
+
+func Generate(opts GenerateOptions) (Generated, error) {
+
+	return GenerateContext(context.Background(), opts)
+}
+
+//  Same as Generate, but gives up when the context is canceled or its
+//  deadline passes.
+
+func GenerateContext(ctx context.Context, opts GenerateOptions) (Generated, error) {
+
+	if opts.Clues < 0 || opts.Clues > classic.numCels {
+		return Generated{}, fmt.Errorf("%w: clue count %d", ErrOutOfRange, opts.Clues)
+	}
+	if opts.Symmetry < 0 || opts.Symmetry >= numSymmetries {
+		return Generated{}, fmt.Errorf("%w: %v", ErrOutOfRange, opts.Symmetry)
+	}
+	if opts.Difficulty < AnyDifficulty || opts.Difficulty >= numDifficulties {
+		return Generated{}, fmt.Errorf("%w: %v", ErrOutOfRange, opts.Difficulty)
+	}
+
+	r := rand.New(rand.NewSource(opts.Seed))
+
+	for attempt := 0; attempt < maxGenerateAttempts; attempt++ {
+		gen, err := generateOnce(ctx, r, opts)
+		if err != nil {
+			return Generated{}, err
+		}
+		if opts.Difficulty == AnyDifficulty || gen.Grade.Difficulty == opts.Difficulty {
+			return gen, nil
+		}
+	}
+	return Generated{}, ErrNoPuzzle
+}
+
+// Exported struct for marshaling a generated puzzle to JSON.
+// Seed, Clues, Symmetry and Difficulty hold the options on the way in,
+// and are replaced by those of the generated puzzle.
+type JsonGenerated struct {
+	Puzzle     Grid       `json:"puzzle"`
+	Solution   Grid       `json:"solution"`
+	Seed       int64      `json:"seed"`
+	Clues      int        `json:"clues"`
+	Symmetry   Symmetry   `json:"symmetry"`
+	Difficulty Difficulty `json:"difficulty"`
+	Score      int        `json:"score"`
+	Status     string     `json:"status"`
+	Code       string     `json:"code,omitempty"` // Machine-readable error code.  See ErrorCode
+
+	// Variant rules the puzzle follows
+	Variants
+
+	// Shade every cel as odd or even on the way in.  The shaded cels
+	// are returned in Odd and Even
+	Parity bool `json:"parity,omitempty"`
+	Constraints
+}
+
+// JSON version of Generate.  Copies status into the JsonGenerated
+// struct the same way Jsolve does for a JsonGrid.
+
+func Jgenerate(jGenP *JsonGenerated) {
+
+	JgenerateContext(context.Background(), jGenP)
+}
+
+// Same as Jgenerate, but gives up when the context is canceled or
+// its deadline passes.
+
+func JgenerateContext(ctx context.Context, jGenP *JsonGenerated) {
+
+	gen, err := GenerateContext(ctx, GenerateOptions{
+		Seed:       jGenP.Seed,
+		Clues:      jGenP.Clues,
+		Symmetry:   jGenP.Symmetry,
+		Difficulty: jGenP.Difficulty,
+		Variants:   jGenP.Variants,
+		Parity:     jGenP.Parity,
+	})
+	if err != nil {
+		jGenP.Status = fmt.Sprintf("%v", err)
+		jGenP.Code = ErrorCode(err)
+		return
+	}
+
+	jGenP.Puzzle = gen.Puzzle
+	jGenP.Solution = gen.Solution
+	jGenP.Clues = gen.Clues
+	jGenP.Difficulty = gen.Grade.Difficulty
+	jGenP.Score = gen.Grade.Score
+	jGenP.Constraints = gen.Constraints
+	jGenP.Status = fmt.Sprintf("Success")
+	jGenP.Code = ""
+}
@@ -0,0 +1,182 @@
+// "go test" program for the puzzle generator
+
+package sudoku
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// Check a generated puzzle is unique, agrees with its solution and
+// has the clue count it claims
+
+func checkGenerated(t *testing.T, name string, gen Generated) {
+
+	n, err := CountSolutions(&gen.Puzzle, 2)
+	if err != nil || n != 1 {
+		t.Error(fmt.Sprintf("%s: puzzle not unique.  Returned: %d, %v", name, n, err))
+	}
+
+	soln := gen.Puzzle
+	if err := Solve(&soln); err != nil || soln != gen.Solution {
+		t.Error(fmt.Sprintf("%s: solution does not match.  Returned: %v", name, err))
+	}
+
+	clues := 0
+	for row := 0; row < GridSize; row++ {
+		for col := 0; col < GridSize; col++ {
+			if gen.Puzzle[row][col] != Blank {
+				clues++
+			}
+		}
+	}
+	if clues != gen.Clues {
+		t.Error(fmt.Sprintf("%s: %d clues, reported %d", name, clues, gen.Clues))
+	}
+}
+
+func TestGenerate(t *testing.T) {
+
+	opts := GenerateOptions{Seed: 42, Difficulty: AnyDifficulty}
+	gen, err := Generate(opts)
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Generate failed.  Returned: %v", err))
+	}
+	checkGenerated(t, "default", gen)
+
+	// The same seed gives the same puzzle, another seed a different one
+	again, _ := Generate(opts)
+	if again.Puzzle != gen.Puzzle {
+		t.Error("Same seed gave a different puzzle")
+	}
+	opts.Seed++
+	if other, _ := Generate(opts); other.Puzzle == gen.Puzzle {
+		t.Error("Different seeds gave the same puzzle")
+	}
+
+	// The clue target is met exactly when it is above the minimum
+	gen, err = Generate(GenerateOptions{Seed: 7, Clues: 35, Difficulty: AnyDifficulty})
+	if err != nil || gen.Clues != 35 {
+		t.Error(fmt.Sprintf("Wrong clue count.  Returned: %d, %v", gen.Clues, err))
+	}
+	checkGenerated(t, "35 clues", gen)
+
+	// Gives up once the deadline has passed
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	if _, err := GenerateContext(ctx, GenerateOptions{Seed: 42, Difficulty: AnyDifficulty}); !errors.Is(err, ErrTimeout) {
+		t.Error(fmt.Sprintf("Expected ErrTimeout.  Returned: %v", err))
+	}
+}
+
+func TestGenerateSymmetry(t *testing.T) {
+
+	for sym := Rotational; sym < numSymmetries; sym++ {
+		gen, err := Generate(GenerateOptions{Seed: 3, Symmetry: sym, Difficulty: AnyDifficulty})
+		if err != nil {
+			t.Error(fmt.Sprintf("%v: Generate failed.  Returned: %v", sym, err))
+			continue
+		}
+		checkGenerated(t, sym.String(), gen)
+
+		for idx := 0; idx < classic.numCels; idx++ {
+			for _, other := range symmetricCels(idx, sym) {
+				blank := gen.Puzzle[idx/GridSize][idx%GridSize] == Blank
+				if blank != (gen.Puzzle[other/GridSize][other%GridSize] == Blank) {
+					t.Error(fmt.Sprintf("%v: cels %d and %d break the symmetry", sym, idx, other))
+				}
+			}
+		}
+	}
+}
+
+func TestGenerateDifficulty(t *testing.T) {
+
+	for d := Easy; d < numDifficulties; d++ {
+		gen, err := Generate(GenerateOptions{Seed: 1, Symmetry: Rotational, Difficulty: d})
+		if err != nil || gen.Grade.Difficulty != d {
+			t.Error(fmt.Sprintf("Wrong band for %v.  Returned: %v, %v", d, gen.Grade.Difficulty, err))
+			continue
+		}
+		checkGenerated(t, d.String(), gen)
+	}
+
+	// Too many clues for an extreme puzzle
+	_, err := Generate(GenerateOptions{Seed: 1, Clues: 60, Difficulty: Extreme})
+	if !errors.Is(err, ErrNoPuzzle) {
+		t.Error(fmt.Sprintf("Expected ErrNoPuzzle.  Returned: %v", err))
+	}
+
+	for _, opts := range []GenerateOptions{
+		{Clues: -1},
+		{Clues: classic.numCels + 1},
+		{Symmetry: numSymmetries},
+		{Difficulty: numDifficulties},
+	} {
+		if _, err := Generate(opts); !errors.Is(err, ErrOutOfRange) {
+			t.Error(fmt.Sprintf("Expected ErrOutOfRange for %+v.  Returned: %v", opts, err))
+		}
+	}
+
+	var jGen JsonGenerated
+	jGen.Seed = 5
+	jGen.Difficulty = Medium
+	Jgenerate(&jGen)
+	if jGen.Code != "" || jGen.Difficulty != Medium || jGen.Clues == 0 {
+		t.Error(fmt.Sprintf("Jgenerate failed.  Returned: %s", jGen.Status))
+	}
+}
+
+func TestGenerateRules(t *testing.T) {
+
+	// No grid meets every chess rule with the diagonals and no
+	// consecutive neighbours
+	impossible := Variants{Diagonals: true, AntiKnight: true, AntiKing: true, NonConsecutive: true}
+	if _, err := Generate(GenerateOptions{Seed: 1, Difficulty: AnyDifficulty, Variants: impossible}); !errors.Is(err, ErrNoPuzzle) {
+		t.Error(fmt.Sprintf("Impossible rules.  Expected ErrNoPuzzle.  Returned: %v", err))
+	}
+
+	// Rules that take a long search to rule out stop at the deadline
+	slow := Variants{Diagonals: true, Windows: true, AntiKing: true, NonConsecutive: true}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := GenerateContext(ctx, GenerateOptions{Seed: 1, Difficulty: AnyDifficulty, Variants: slow}); !errors.Is(err, ErrTimeout) {
+		t.Error(fmt.Sprintf("Slow rules.  Expected ErrTimeout.  Returned: %v", err))
+	}
+}
+
+func TestGenerateParity(t *testing.T) {
+
+	opts := GenerateOptions{Seed: 11, Difficulty: AnyDifficulty, Parity: true}
+	gen, err := Generate(opts)
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Generate failed.  Returned: %v", err))
+	}
+
+	soln := SizedGridOf(&gen.Solution)
+	if want := parityFrom(&soln); fmt.Sprint(gen.Constraints) != fmt.Sprint(want) {
+		t.Error(fmt.Sprintf("Shading does not match the solution.  Returned: %+v", gen.Constraints))
+	}
+
+	// Unique with the shading, though not without it
+	puzzle := SizedGridOf(&gen.Puzzle)
+	puzzle.Constraints = gen.Constraints
+	for _, solver := range []Solver{Backtrack, DancingLinks} {
+		if n, err := CountSolutionsSizedWith(context.Background(), solver, &puzzle, 2); err != nil || n != 1 {
+			t.Error(fmt.Sprintf("%T: puzzle not unique.  Returned: %d, %v", solver, n, err))
+		}
+	}
+	if n, _ := CountSolutions(&gen.Puzzle, 2); n != 2 {
+		t.Error(fmt.Sprintf("Puzzle unique without the shading.  Returned: %d clues", gen.Clues))
+	}
+	checkSizedLogic(t, "parity", &puzzle, &soln)
+
+	jGen := JsonGenerated{Seed: opts.Seed, Difficulty: AnyDifficulty, Parity: true}
+	Jgenerate(&jGen)
+	if jGen.Code != "" || jGen.Puzzle != gen.Puzzle || len(jGen.Odd) != len(gen.Constraints.Odd) {
+		t.Error(fmt.Sprintf("Jgenerate ignored parity.  Returned: %s", jGen.Status))
+	}
+}
@@ -0,0 +1,199 @@
+//
+// Copyright 2020, 2021 Kenneth J. Gibson
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//
+// Difficulty grading.
+// Rates a puzzle by solving it with the logical solver.  Each technique
+// belongs to a difficulty tier and has a cost per use.  The score starts
+// at the base of the tier of the hardest technique needed, then adds the
+// cost of every use and the number of search nodes if logic stalled.
+// The difficulty is the band the score falls in, so a puzzle that needs
+// many medium techniques can rate as hard.
+//
+
+package sudoku
+
+import (
+	"context"
+	"fmt"
+)
+
+// Difficulty bands, easiest first
+type Difficulty int
+
+const (
+	Easy Difficulty = iota
+	Medium
+	Hard
+	Expert
+	Extreme
+	numDifficulties
+)
+
+// Accepts any band where a Difficulty is asked for, e.g. by Generate
+const AnyDifficulty Difficulty = -1
+
+var difficultyNames = [numDifficulties]string{"easy", "medium", "hard", "expert", "extreme"}
+
+// Lowest score in each band
+var difficultyBase = [numDifficulties]int{0, 100, 300, 600, 1000}
+
+func (d Difficulty) String() string {
+	if d == AnyDifficulty {
+		return "any"
+	}
+	if d < 0 || d >= numDifficulties {
+		return fmt.Sprintf("Difficulty(%d)", int(d))
+	}
+	return difficultyNames[d]
+}
+
+// Difficulties are sent by name in JSON
+func (d Difficulty) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Difficulty) UnmarshalText(text []byte) error {
+	if string(text) == "any" {
+		*d = AnyDifficulty
+		return nil
+	}
+	for i, name := range difficultyNames {
+		if string(text) == name {
+			*d = Difficulty(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown difficulty %q", text)
+}
+
+// Tier and cost per use of each technique
+var techniqueGrades = [numTechniques]struct {
+	tier Difficulty
+	cost int
+}{
+	NakedSingle:      {Easy, 1},
+	HiddenSingle:     {Easy, 1},
+	NakedPair:        {Medium, 10},
+	NakedTriple:      {Medium, 15},
+	HiddenPair:       {Medium, 15},
+	HiddenTriple:     {Medium, 20},
+	NakedQuad:        {Hard, 30},
+	HiddenQuad:       {Hard, 35},
+	PointingPair:     {Medium, 10},
+	BoxLineReduction: {Medium, 10},
+	XWing:            {Hard, 30},
+	SimpleColoring:   {Hard, 40},
+	XYWing:           {Hard, 40},
+	Swordfish:        {Expert, 50},
+	XYZWing:          {Expert, 50},
+	Jellyfish:        {Expert, 70},
+	UniqueRectangle:  {Expert, 40},
+	Backtracking:     {Extreme, 100},
+}
+
+// Grade of a puzzle, along with the logical solve it is based on
+type GradeResult struct {
+	Difficulty Difficulty `json:"difficulty"`
+	Score      int        `json:"score"`
+	LogicResult
+}
+
+//  Work out the score and difficulty band of a logical solve
+
+func gradeOf(result LogicResult) GradeResult {
+
+	var tier Difficulty
+	var cost int
+	for tech, n := range result.Counts {
+		if techniqueGrades[tech].tier > tier {
+			tier = techniqueGrades[tech].tier
+		}
+		cost += n * techniqueGrades[tech].cost
+	}
+
+	score := difficultyBase[tier] + cost + result.SearchNodes
+
+	band := Easy
+	for d := Easy; d < numDifficulties; d++ {
+		if score >= difficultyBase[d] {
+			band = d
+		}
+	}
+	return GradeResult{Difficulty: band, Score: score, LogicResult: result}
+}
+
+//  Public entry point for grading a puzzle.
+//  The caller's Grid is not modified.  Returns the same errors as Solve.
+
+func Grade(configP *Grid) (GradeResult, error) {
+
+	return GradeContext(context.Background(), configP)
+}
+
+//  Same as Grade, but gives up when the context is canceled or its
+//  deadline passes.
+
+func GradeContext(ctx context.Context, configP *Grid) (GradeResult, error) {
+
+	return gradeGrid(ctx, configP)
+}
+
+//  Grade either kind of grid
+
+func gradeGrid(ctx context.Context, configP celGrid) (GradeResult, error) {
+
+	var lg logicGrid
+
+	result, err := lg.logicalResult(ctx, configP)
+	if err != nil {
+		return GradeResult{}, err
+	}
+	return gradeOf(result), nil
+}
+
+// Exported struct for marshaling a grade to/from JSON.
+// The puzzle is sent in Puzzle and is not changed.
+type JsonGrade struct {
+	Puzzle Grid   `json:"puzzle"`
+	Status string `json:"status"`
+	Code   string `json:"code,omitempty"` // Machine-readable error code.  See ErrorCode
+	GradeResult
+}
+
+// JSON version of Grade.  Copies status into the JsonGrade struct
+// the same way Jsolve does for a JsonGrid.
+
+func Jgrade(jGradeP *JsonGrade) {
+
+	JgradeContext(context.Background(), jGradeP)
+}
+
+// Same as Jgrade, but gives up when the context is canceled or
+// its deadline passes.
+
+func JgradeContext(ctx context.Context, jGradeP *JsonGrade) {
+
+	grade, err := GradeContext(ctx, &jGradeP.Puzzle)
+	jGradeP.GradeResult = grade
+	if err != nil {
+		jGradeP.Status = fmt.Sprintf("%v", err)
+		jGradeP.Code = ErrorCode(err)
+		return
+	}
+	jGradeP.Status = fmt.Sprintf("Success")
+	jGradeP.Code = ""
+}
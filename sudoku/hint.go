@@ -0,0 +1,130 @@
+//
+// Copyright 2020, 2021 Kenneth J. Gibson
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//
+// Hints.
+// Finds the easiest deduction a player can make next from a partly
+// filled grid, using the logical solver.  The player's entries are
+// treated like clues, so a wrong entry that leaves no solution is
+// reported rather than hinted around.
+//
+
+package sudoku
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Exported struct for marshaling a hint to/from JSON.
+// The partly filled grid is sent in Puzzle and is not changed.
+type JsonHint struct {
+	Puzzle Grid   `json:"puzzle"`
+	Hint   *Step  `json:"hint,omitempty"` // Next deduction.  Nil on error
+	Status string `json:"status"`
+	Code   string `json:"code,omitempty"` // Machine-readable error code.  See ErrorCode
+
+	// Every clashing pair of cels when Code is "conflict".  See Validate
+	Conflicts []Conflict `json:"conflicts,omitempty"`
+}
+
+//  Public entry point for a hint.
+//  Returns the easiest deduction that can be made next, as the first
+//  step SolveSteps would take.  If logic alone is stalled, the step is a
+//  Backtracking step that places the single cel with the fewest
+//  candidates, rather than giving away the whole solution.
+//  The caller's Grid is not modified.  Returns the same errors as Solve,
+//  except that any grid with no solution gives ErrUnsolvable, meaning an
+//  entry is wrong.  Returns ErrSolved if there are no blank cels.
+
+func Hint(configP *Grid) (Step, error) {
+
+	return HintContext(context.Background(), configP)
+}
+
+//  Same as Hint, but gives up when the context is canceled or its
+//  deadline passes.
+
+func HintContext(ctx context.Context, configP *Grid) (Step, error) {
+
+	var lg logicGrid
+
+	if err := lg.prepareLogical(ctx, configP); err != nil {
+		// The cel left without candidates is not the wrong entry, so
+		// don't point the player at it
+		if errors.Is(err, ErrNoCandidates) {
+			err = ErrUnsolvable
+		}
+		return Step{}, err
+	}
+	if lg.filled == lg.geo.numCels {
+		return Step{}, ErrSolved
+	}
+
+	if st := lg.nextStep(); st != nil {
+		return st.export(lg.geo), nil
+	}
+
+	// Reveal only the cel with the fewest candidates
+	idx := -1
+	for i := 0; i < lg.geo.numCels; i++ {
+		if lg.value[i] == Blank && (idx < 0 || lg.cands[i].count() < lg.cands[idx].count()) {
+			idx = i
+		}
+	}
+	st, err := lg.searchStep(ctx)
+	if err != nil {
+		return Step{}, err
+	}
+	for _, p := range st.placed {
+		if p.idx == idx {
+			st.placed = []celVal{p}
+			break
+		}
+	}
+	return st.export(lg.geo), nil
+}
+
+// JSON version of Hint.  Copies status into the JsonHint struct
+// the same way Jsolve does for a JsonGrid.
+
+func Jhint(jHintP *JsonHint) {
+
+	JhintContext(context.Background(), jHintP)
+}
+
+// Same as Jhint, but gives up when the context is canceled or
+// its deadline passes.
+
+func JhintContext(ctx context.Context, jHintP *JsonHint) {
+
+	jHintP.Hint = nil
+	jHintP.Conflicts = nil
+
+	hint, err := HintContext(ctx, &jHintP.Puzzle)
+	if err != nil {
+		jHintP.Status = fmt.Sprintf("%v", err)
+		jHintP.Code = ErrorCode(err)
+		if errors.Is(err, ErrConflict) {
+			jHintP.Conflicts, _ = Validate(&jHintP.Puzzle)
+		}
+		return
+	}
+	jHintP.Hint = &hint
+	jHintP.Status = fmt.Sprintf("Success")
+	jHintP.Code = ""
+}
@@ -0,0 +1,74 @@
+// "go test" program for hints
+
+package sudoku
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestHint(t *testing.T) {
+
+	var soln Grid = easyGrid
+	Solve(&soln)
+
+	var puzzle Grid = easyGrid
+	hint, err := Hint(&puzzle)
+	if err != nil || puzzle != easyGrid {
+		t.Fatal(fmt.Sprintf("Hint failed.  Returned: %v", err))
+	}
+	if hint.Technique != NakedSingle || len(hint.Placed) != 1 {
+		t.Error(fmt.Sprintf("Expected a naked single.  Returned: %v", hint.Technique))
+	}
+	for _, p := range hint.Placed {
+		if soln[p.Row][p.Col] != p.Value {
+			t.Error(fmt.Sprintf("Hint placed %d at %d, %d.  Solution has %d", p.Value, p.Row, p.Col, soln[p.Row][p.Col]))
+		}
+	}
+	fmt.Printf("Hint: %s\n", hint.Explanation)
+
+	// Nothing to hint on a solved grid
+	if _, err := Hint(&soln); !errors.Is(err, ErrSolved) {
+		t.Error(fmt.Sprintf("Expected ErrSolved.  Returned: %v", err))
+	}
+
+	// No technique applies to a blank grid, so a single cel is searched for
+	var blank Grid
+	hint, err = Hint(&blank)
+	if err != nil || hint.Technique != Backtracking || len(hint.Placed) != 1 {
+		t.Error(fmt.Sprintf("Expected one searched cel.  Returned: %v %v", hint.Technique, err))
+	}
+}
+
+func TestHintWrongEntry(t *testing.T) {
+
+	var soln Grid = hardGrid
+	Solve(&soln)
+
+	// Enter a wrong value that does not clash with anything yet
+	for idx := 0; idx < classic.numCels; idx++ {
+		row, col := idx/GridSize, idx%GridSize
+		if hardGrid[row][col] != Blank {
+			continue
+		}
+		for val := MinVal; val <= MaxVal; val++ {
+			var puzzle Grid = hardGrid
+			puzzle[row][col] = val
+			if conflicts, _ := Validate(&puzzle); val == soln[row][col] || len(conflicts) > 0 {
+				continue
+			}
+			if _, err := Hint(&puzzle); !errors.Is(err, ErrUnsolvable) {
+				t.Error(fmt.Sprintf("Wrong entry %d at %d, %d not caught.  Returned: %v", val, row, col, err))
+			}
+			break
+		}
+	}
+
+	var jHint JsonHint
+	jHint.Puzzle = illegalGrid
+	Jhint(&jHint)
+	if jHint.Code != "conflict" || jHint.Hint != nil || len(jHint.Conflicts) == 0 {
+		t.Error(fmt.Sprintf("Failed to catch illegal config.  Returned: %s", jHint.Status))
+	}
+}
@@ -0,0 +1,112 @@
+//
+// Copyright 2020, 2021 Kenneth J. Gibson
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//
+// Jigsaw (irregular region) grids.
+// A region map gives the region of every cel, and the regions replace
+// the boxes.  Each region must be a single connected group of cels, as
+// many as the side of the grid, so that it can hold each value once.
+// Region geometries are built for each puzzle rather than shared, since
+// every puzzle can have its own map.
+//
+
+package sudoku
+
+import (
+	"fmt"
+)
+
+// Region of each cel, by row and column.  Regions are numbered from 0
+// to one less than the side of the grid.
+type RegionMap [][]int
+
+//  Check that the map tiles a grid of the given side with connected
+//  regions of the right size.  Returns ErrBadRegions describing the first
+//  problem found.
+
+func (rm RegionMap) check(size int) error {
+
+	if len(rm) != size {
+		return fmt.Errorf("%w: %d rows for size %d", ErrBadRegions, len(rm), size)
+	}
+	counts := make([]int, size)
+	for row, ids := range rm {
+		if len(ids) != size {
+			return fmt.Errorf("%w: %d cels in row %d for size %d", ErrBadRegions, len(ids), row, size)
+		}
+		for col, id := range ids {
+			if id < 0 || id >= size {
+				return fmt.Errorf("%w: region %d at cel %d, %d", ErrBadRegions, id, row, col)
+			}
+			counts[id]++
+		}
+	}
+
+	for id, cels := range rm.cels(size) {
+		if counts[id] != size {
+			return fmt.Errorf("%w: region %d has %d cels, want %d", ErrBadRegions, id, counts[id], size)
+		}
+		if !connected(cels, size) {
+			return fmt.Errorf("%w: region %d is not connected", ErrBadRegions, id)
+		}
+	}
+	return nil
+}
+
+// Cels of each region, in cel order.  Assumes the map has been checked
+// for size and region numbers
+
+func (rm RegionMap) cels(size int) [][]int {
+
+	cels := make([][]int, size)
+	for row, ids := range rm {
+		for col, id := range ids {
+			cels[id] = append(cels[id], row*size+col)
+		}
+	}
+	return cels
+}
+
+//  True if the cels form one group joined across their sides.
+//  Flood fills from the first cel.
+
+func connected(cels []int, size int) bool {
+
+	in := make(map[int]bool)
+	for _, idx := range cels {
+		in[idx] = true
+	}
+
+	reached := map[int]bool{cels[0]: true}
+	todo := []int{cels[0]}
+	for len(todo) > 0 {
+		idx := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+
+		row, col := idx/size, idx%size
+		for _, next := range []struct{ row, col int }{{row - 1, col}, {row + 1, col}, {row, col - 1}, {row, col + 1}} {
+			if next.row < 0 || next.row >= size || next.col < 0 || next.col >= size {
+				continue
+			}
+			n := next.row*size + next.col
+			if in[n] && !reached[n] {
+				reached[n] = true
+				todo = append(todo, n)
+			}
+		}
+	}
+	return len(reached) == len(cels)
+}
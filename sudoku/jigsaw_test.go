@@ -0,0 +1,144 @@
+// "go test" program for jigsaw regions
+
+package sudoku
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// The standard boxes with three corner cels traded between neighbours
+var jigsawMap = RegionMap{
+	{0, 0, 0, 0, 1, 1, 2, 2, 2},
+	{0, 0, 0, 1, 1, 1, 2, 2, 2},
+	{0, 0, 1, 1, 1, 1, 2, 2, 2},
+	{3, 3, 3, 4, 4, 4, 5, 5, 5},
+	{3, 3, 3, 4, 4, 4, 5, 5, 5},
+	{3, 3, 3, 4, 4, 7, 5, 5, 8},
+	{6, 6, 6, 4, 7, 7, 5, 8, 8},
+	{6, 6, 6, 7, 7, 7, 8, 8, 8},
+	{6, 6, 6, 7, 7, 7, 8, 8, 8},
+}
+
+func jigsawGrid() SizedGrid {
+	sg := NewSizedGrid(classic.shape)
+	sg.Regions = jigsawMap
+	return sg
+}
+
+func TestJigsaw(t *testing.T) {
+
+	// Backtracking can take a very long time to fill a blank jigsaw, so
+	// Dancing Links fills it
+	soln := jigsawGrid()
+	if err := SolveSizedWith(context.Background(), DancingLinks, &soln); err != nil || !solvedSized(&soln) {
+		t.Fatal(fmt.Sprintf("Blank jigsaw not solved.  Returned: %v", err))
+	}
+
+	// Each region holds every value
+	seen := make([]valSet, GridSize)
+	for row := range soln.Cels {
+		for col, val := range soln.Cels[row] {
+			seen[jigsawMap[row][col]] |= valBit(val)
+		}
+	}
+	for id, vals := range seen {
+		if vals != classic.allVals {
+			t.Error(fmt.Sprintf("Region %d holds %v", id, vals))
+		}
+	}
+
+	// Puzzles made from the solution, with every fourth cel cleared or
+	// with one cel of each region cleared
+	puzzleWith := func(clear func(row, col int) bool) SizedGrid {
+		puzzle := jigsawGrid()
+		for row := range puzzle.Cels {
+			for col := range puzzle.Cels[row] {
+				if !clear(row, col) {
+					puzzle.Cels[row][col] = soln.Cels[row][col]
+				}
+			}
+		}
+		return puzzle
+	}
+	sparse := puzzleWith(func(row, col int) bool { return (row+col)%4 == 0 })
+	single := puzzleWith(func(row, col int) bool {
+		return jigsawMap.cels(GridSize)[jigsawMap[row][col]][0] == row*GridSize+col
+	})
+
+	for _, solver := range []Solver{Backtrack, DancingLinks} {
+		// Solving replaces the Cels, so sparse is left as it was
+		config := sparse
+		if err := SolveSizedWith(context.Background(), solver, &config); err != nil || !solvedSized(&config) {
+			t.Error(fmt.Sprintf("%T: jigsaw puzzle not solved.  Returned: %v", solver, err))
+		}
+		if n, err := CountSolutionsSizedWith(context.Background(), solver, &single, 2); err != nil || n != 1 {
+			t.Error(fmt.Sprintf("%T: puzzle not unique.  Returned: %d, %v", solver, n, err))
+		}
+	}
+
+	// Clashes follow the regions, not the boxes
+	sg := jigsawGrid()
+	sg.Cels[0][3], sg.Cels[1][4] = 6, 6
+	if conflicts, err := ValidateSized(&sg); err != nil || len(conflicts) != 0 {
+		t.Error(fmt.Sprintf("Clash in a replaced box.  Returned: %v, %v", conflicts, err))
+	}
+	sg.Cels[1][4], sg.Cels[1][0] = Blank, 6
+	conflicts, err := ValidateSized(&sg)
+	if err != nil || len(conflicts) != 1 || conflicts[0].Unit != (Unit{"region", 0}) {
+		t.Error(fmt.Sprintf("Region clash not reported.  Returned: %v, %v", conflicts, err))
+	}
+}
+
+func TestBadRegions(t *testing.T) {
+
+	// Copy the good map and break one thing at a time
+	mapWith := func(change func(rm RegionMap)) RegionMap {
+		rm := make(RegionMap, len(jigsawMap))
+		for row := range jigsawMap {
+			rm[row] = append([]int(nil), jigsawMap[row]...)
+		}
+		change(rm)
+		return rm
+	}
+	bad := map[string]RegionMap{
+		"short":        mapWith(func(rm RegionMap) { rm[8] = rm[8][:8] }),
+		"rows":         jigsawMap[:8],
+		"out of range": mapWith(func(rm RegionMap) { rm[0][0] = 9 }),
+		"uneven":       mapWith(func(rm RegionMap) { rm[0][4] = 0 }),
+		"disconnected": mapWith(func(rm RegionMap) { rm[0][0], rm[0][4] = 1, 0 }),
+	}
+	for name, rm := range bad {
+		sg := NewSizedGrid(classic.shape)
+		sg.Regions = rm
+		if err := SolveSized(&sg); !errors.Is(err, ErrBadRegions) {
+			t.Error(fmt.Sprintf("%s: expected ErrBadRegions.  Returned: %v", name, err))
+		}
+	}
+}
+
+func TestJsolveJigsaw(t *testing.T) {
+
+	// Blank, so solved with Dancing Links as above
+	body, _ := json.Marshal(map[string]interface{}{"regions": jigsawMap, "solver": "dlx"})
+	var jGrid JsonGrid
+	if err := json.Unmarshal(body, &jGrid); err != nil {
+		t.Fatal(fmt.Sprintf("Region map not decoded.  Returned: %v", err))
+	}
+
+	Jsolve(&jGrid)
+	soln := SizedGridOf(&jGrid.Solution)
+	soln.Regions = jigsawMap
+	if jGrid.Code != "" || !solvedSized(&soln) {
+		t.Error(fmt.Sprintf("Jsolve ignored the regions.  Returned: %s", jGrid.Status))
+	}
+
+	jGrid.Regions = jigsawMap[1:]
+	Jsolve(&jGrid)
+	if jGrid.Code != "bad_regions" {
+		t.Error(fmt.Sprintf("Failed to catch a bad region map.  Returned: %s", jGrid.Status))
+	}
+}
@@ -0,0 +1,160 @@
+//
+// Copyright 2020, 2021 Kenneth J. Gibson
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//
+// Killer Sudoku cages.
+// A cage is a group of cels whose values must add up to its sum, with no
+// value repeated.  Each cage becomes a unit of the engine geometry, which
+// takes care of the repeats, and holds a target sum.  Unlike the other
+// units a cage does not need every value, so it only limits where values
+// go, and never says where one must go.
+//
+// The sum prunes the options of every blank cel in the cage, not just
+// the last one.  A table lists, for each count of cels and each sum, the
+// sets of different values that add up to it.  The options for a blank
+// cel are the values of the sets that fit the cels still blank and the
+// sum still missing, and that avoid the values already placed.
+//
+
+package sudoku
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Largest grid side that can have cages.  The combination table for a
+// side n has 2^n entries
+const MaxCageSize = 16
+
+// A killer cage.  Cels is the list of cels in the cage, and Sum the total
+// of their values
+type Cage struct {
+	Cels []Cel `json:"cels"`
+	Sum  int   `json:"sum"`
+}
+
+//  Check the cages against a grid of the given side.  Every cel must be on
+//  the grid and in one cage at most, and every sum must be possible for
+//  different values in that many cels.  Returns ErrBadCages describing
+//  the first problem found.
+
+func checkCages(cages []Cage, size int) error {
+
+	if size > MaxCageSize {
+		return fmt.Errorf("%w: cages on a grid of size %d", ErrBadCages, size)
+	}
+
+	inCage := make([]bool, size*size)
+	for i, cage := range cages {
+		n := len(cage.Cels)
+		if n == 0 || n > size {
+			return fmt.Errorf("%w: cage %d has %d cels", ErrBadCages, i, n)
+		}
+		for _, cel := range cage.Cels {
+			if cel.Row < 0 || cel.Row >= size || cel.Col < 0 || cel.Col >= size {
+				return fmt.Errorf("%w: cage %d cel %d, %d is off the grid", ErrBadCages, i, cel.Row, cel.Col)
+			}
+			idx := cel.Row*size + cel.Col
+			if inCage[idx] {
+				return fmt.Errorf("%w: cel %d, %d is in more than one cage", ErrBadCages, cel.Row, cel.Col)
+			}
+			inCage[idx] = true
+		}
+
+		// Smallest and largest totals of n different values
+		least, most := n*(n+1)/2, n*(2*size-n+1)/2
+		if cage.Sum < least || cage.Sum > most {
+			return fmt.Errorf("%w: cage %d sum %d is not possible in %d cels", ErrBadCages, i, cage.Sum, n)
+		}
+	}
+	return nil
+}
+
+//  Combination tables already built, by grid side.  Entry [n][sum] lists
+//  every set of n different values that adds up to sum.
+
+var combinations = struct {
+	sync.Mutex
+	bySize map[int][][][]valSet
+}{bySize: make(map[int][][][]valSet)}
+
+func combosFor(size int) [][][]valSet {
+
+	combinations.Lock()
+	defer combinations.Unlock()
+
+	if combos, ok := combinations.bySize[size]; ok {
+		return combos
+	}
+
+	maxSum := size * (size + 1) / 2
+	combos := make([][][]valSet, size+1)
+	for n := range combos {
+		combos[n] = make([][]valSet, maxSum+1)
+	}
+
+	// Every subset of the values 1 to size.  Bit 0 is never set
+	for bits := 0; bits < 1<<size; bits++ {
+		vals := valSet(bits) << 1
+		sum := 0
+		for vs := vals; vs != 0; vs &^= valBit(vs.first()) {
+			sum += int(vs.first())
+		}
+		combos[vals.count()][sum] = append(combos[vals.count()][sum], vals)
+	}
+
+	combinations.bySize[size] = combos
+	return combos
+}
+
+//  Values that can still go in a blank cel of a cage unit and leave its
+//  sum reachable by the other blank cels
+
+func (gp *grid) cageOptions(unit int) valSet {
+
+	left := len(gp.geo.unitCels[unit]) - gp.used[unit].count()
+	need := gp.geo.sums[unit] - gp.sum[unit]
+	if left <= 0 || need <= 0 || need >= len(gp.geo.combos[left]) {
+		return 0
+	}
+
+	var opts valSet
+	for _, combo := range gp.geo.combos[left][need] {
+		if combo&gp.used[unit] == 0 {
+			opts |= combo
+		}
+	}
+	return opts
+}
+
+//  Check the sums of the cages once the given values are loaded.  A cage
+//  over its sum, or full and under it, is reported with ErrCageSum.
+
+func (gp *grid) checkCageSums() error {
+
+	for unit, target := range gp.geo.sums {
+		if target == 0 {
+			continue
+		}
+		full := gp.used[unit].count() == len(gp.geo.unitCels[unit])
+		if gp.sum[unit] > target || (full && gp.sum[unit] != target) {
+			cel := gp.geo.celOf(gp.geo.unitCels[unit][0])
+			return &CelError{Err: ErrCageSum, Row: cel.Row, Col: cel.Col, Unit: gp.geo.units[unit]}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+// "go test" program for killer cages
+
+package sudoku
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// Blank grid with cages over the given cels, each summing to its cels in
+// the solution
+func killerGrid(soln *Grid, cages [][]Cel) SizedGrid {
+	sg := NewSizedGrid(classic.shape)
+	for _, cels := range cages {
+		sum := 0
+		for _, cel := range cels {
+			sum += int(soln[cel.Row][cel.Col])
+		}
+		sg.Cages = append(sg.Cages, Cage{Cels: cels, Sum: sum})
+	}
+	return sg
+}
+
+// True if every cage adds up to its sum
+func sumsHold(sg *SizedGrid) bool {
+	for _, cage := range sg.Cages {
+		sum := 0
+		for _, cel := range cage.Cels {
+			sum += int(sg.Cels[cel.Row][cel.Col])
+		}
+		if sum != cage.Sum {
+			return false
+		}
+	}
+	return true
+}
+
+func TestKiller(t *testing.T) {
+
+	var soln Grid = hardGrid
+	Solve(&soln)
+
+	// Cages of two cels across each row, and of three cels down each
+	// column of boxes
+	var pairs, triples [][]Cel
+	for row := 0; row < GridSize; row++ {
+		for col := 0; col < GridSize; col += 2 {
+			cels := []Cel{{row, col}}
+			if col+1 < GridSize {
+				cels = append(cels, Cel{row, col + 1})
+			}
+			pairs = append(pairs, cels)
+		}
+	}
+	for row := 0; row < GridSize; row += 3 {
+		for col := 0; col < GridSize; col++ {
+			triples = append(triples, []Cel{{row, col}, {row + 1, col}, {row + 2, col}})
+		}
+	}
+
+	// The pairs leave 16 solutions.  Both solvers must find the same ones
+	for _, solver := range []Solver{Backtrack, DancingLinks} {
+		sg := killerGrid(&soln, pairs)
+		seen := make(map[string]bool)
+		n, err := solver.SizedSolutions(context.Background(), &sg, 0, func(found SizedGrid) bool {
+			if !solvedSized(&found) || !sumsHold(&found) {
+				t.Error(fmt.Sprintf("%T: sums broken in %v", solver, found.Cels))
+			}
+			seen[fmt.Sprint(found.Cels)] = true
+			return true
+		})
+		if err != nil || n != 16 || len(seen) != 16 || !seen[fmt.Sprint(SizedGridOf(&soln).Cels)] {
+			t.Error(fmt.Sprintf("%T: expected 16 solutions.  Returned: %d, %d, %v", solver, n, len(seen), err))
+		}
+
+		sg = killerGrid(&soln, triples)
+		if err := SolveSizedWith(context.Background(), solver, &sg); err != nil || !solvedSized(&sg) || !sumsHold(&sg) {
+			t.Error(fmt.Sprintf("%T: sums broken.  Returned: %v", solver, err))
+		}
+	}
+
+	// A repeat inside a cage is a conflict in the cage unit alone
+	sg := NewSizedGrid(classic.shape)
+	sg.Cages = []Cage{{Cels: []Cel{{0, 0}, {1, 3}}, Sum: 3}}
+	sg.Cels[0][0], sg.Cels[1][3] = 1, 1
+	conflicts, err := ValidateSized(&sg)
+	if err != nil || len(conflicts) != 1 || conflicts[0].Unit != (Unit{"cage", 0}) {
+		t.Error(fmt.Sprintf("Cage clash not reported.  Returned: %v, %v", conflicts, err))
+	}
+
+	// Given values over the sum, or a full cage under it
+	sg = killerGrid(&soln, pairs)
+	sg.Cages[0].Sum = 4
+	sg.Cels[0][0] = 5
+	if err := SolveSized(&sg); !errors.Is(err, ErrCageSum) {
+		t.Error(fmt.Sprintf("Sum exceeded.  Expected ErrCageSum.  Returned: %v", err))
+	}
+	sg.Cels[0][0], sg.Cels[0][1] = 1, 2
+	var celErr *CelError
+	if err := SolveSized(&sg); !errors.As(err, &celErr) || celErr.Unit != (Unit{"cage", 0}) {
+		t.Error(fmt.Sprintf("Sum not met.  Expected ErrCageSum.  Returned: %v", err))
+	}
+}
+
+func TestKillerLogic(t *testing.T) {
+
+	// Cels of a cage with no other unit in common.  Placing a value in
+	// one leaves the other a single candidate
+	sg := NewSizedGrid(classic.shape)
+	sg.Cages = []Cage{{Cels: []Cel{{0, 0}, {4, 4}}, Sum: 10}}
+	var lg logicGrid
+	if err := lg.prepareLogical(context.Background(), &sg); err != nil {
+		t.Fatal(fmt.Sprintf("Cage not prepared.  Returned: %v", err))
+	}
+	lg.setValue(0, 3)
+	if cands := lg.cands[4*GridSize+4]; cands != valBit(7) {
+		t.Error(fmt.Sprintf("Cage not pruned.  Candidates: %v", cands))
+	}
+
+	// A killer puzzle with a cage over each third of a row and a few of
+	// the hard puzzle's givens.  Logic solves it without search, and its
+	// steps stay true to the solution
+	var soln Grid = hardGrid
+	Solve(&soln)
+	var cages [][]Cel
+	for row := 0; row < GridSize; row++ {
+		for col := 0; col < GridSize; col += 3 {
+			cages = append(cages, []Cel{{row, col}, {row, col + 1}, {row, col + 2}})
+		}
+	}
+	sg = killerGrid(&soln, cages)
+	for _, cel := range []Cel{{0, 0}, {0, 4}, {1, 3}, {2, 1}, {2, 3}, {3, 5}, {4, 1}, {4, 8},
+		{5, 6}, {5, 7}, {6, 7}, {7, 1}, {7, 8}, {8, 2}, {8, 6}} {
+		sg.Cels[cel.Row][cel.Col] = hardGrid[cel.Row][cel.Col]
+	}
+	grade, err := gradeGrid(context.Background(), &sg)
+	if err != nil || grade.Counts[Backtracking] != 0 {
+		t.Error(fmt.Sprintf("Killer puzzle needed search.  Returned: %v, %v", grade.Counts, err))
+	}
+	sizedSoln := SizedGridOf(&soln)
+	checkSizedLogic(t, "killer", &sg, &sizedSoln)
+}
+
+func TestBadCages(t *testing.T) {
+
+	bad := map[string][]Cage{
+		"empty":     {{Sum: 3}},
+		"too long":  {{Cels: make([]Cel, GridSize+1), Sum: 45}},
+		"off grid":  {{Cels: []Cel{{0, 0}, {0, GridSize}}, Sum: 3}},
+		"overlap":   {{Cels: []Cel{{0, 0}, {0, 1}}, Sum: 3}, {Cels: []Cel{{0, 1}, {0, 2}}, Sum: 3}},
+		"too small": {{Cels: []Cel{{0, 0}, {0, 1}}, Sum: 2}},
+		"too big":   {{Cels: []Cel{{0, 0}, {0, 1}}, Sum: 18}},
+	}
+	for name, cages := range bad {
+		sg := NewSizedGrid(classic.shape)
+		sg.Cages = cages
+		if err := SolveSized(&sg); !errors.Is(err, ErrBadCages) {
+			t.Error(fmt.Sprintf("%s: expected ErrBadCages.  Returned: %v", name, err))
+		}
+	}
+
+	// The combination table is too big above 16x16
+	sg := NewSizedGrid(Shape{5, 5})
+	sg.Cages = []Cage{{Cels: []Cel{{0, 0}}, Sum: 1}}
+	if err := SolveSized(&sg); !errors.Is(err, ErrBadCages) {
+		t.Error(fmt.Sprintf("25x25: expected ErrBadCages.  Returned: %v", err))
+	}
+}
+
+func TestJsolveKiller(t *testing.T) {
+
+	var soln Grid = hardGrid
+	Solve(&soln)
+	var cages [][]Cel
+	for row := 0; row < GridSize; row++ {
+		for col := 0; col < GridSize; col += 3 {
+			cages = append(cages, []Cel{{row, col}, {row, col + 1}, {row, col + 2}})
+		}
+	}
+	sg := killerGrid(&soln, cages)
+
+	body, _ := json.Marshal(map[string]interface{}{"cages": sg.Cages})
+	var jGrid JsonGrid
+	if err := json.Unmarshal(body, &jGrid); err != nil || len(jGrid.Cages) != len(cages) {
+		t.Fatal(fmt.Sprintf("Cages not decoded.  Returned: %v", err))
+	}
+
+	Jsolve(&jGrid)
+	sg.Cels = SizedGridOf(&jGrid.Solution).Cels
+	if jGrid.Code != "" || !solvedSized(&sg) || !sumsHold(&sg) {
+		t.Error(fmt.Sprintf("Jsolve ignored the cages.  Returned: %s", jGrid.Status))
+	}
+
+	jGrid.Cages[0].Sum = 2
+	Jsolve(&jGrid)
+	if jGrid.Code != "bad_cages" {
+		t.Error(fmt.Sprintf("Failed to catch a bad cage.  Returned: %s", jGrid.Status))
+	}
+}
@@ -3,6 +3,8 @@
 package sudoku
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"testing"
 )
@@ -167,3 +169,287 @@ func TestHard(t *testing.T) {
 	}
 
 }
+
+func TestUnique(t *testing.T) {
+
+	var testGrid JsonGrid
+
+	testGrid.Solution = medGrid
+
+	Jsolve(&testGrid)
+
+	if testGrid.Status != "Success" || !testGrid.Unique {
+		errString := fmt.Sprintf("Failed unique puzzle.  Returned: %s, unique %v", testGrid.Status, testGrid.Unique)
+		t.Error(errString)
+	} else {
+		fmt.Printf("\nMedium puzzle has a unique solution\n")
+	}
+}
+
+func TestCountSolutions(t *testing.T) {
+
+	var blankGrid Grid
+
+	// A blank grid has billions of solutions.  Make sure the limit is honored
+	count, err := CountSolutions(&blankGrid, 10)
+	if err != nil || count != 10 {
+		errString := fmt.Sprintf("Failed blank grid count.  Returned: %d, %v", count, err)
+		t.Error(errString)
+	}
+
+	// Remove clues from a unique puzzle until it has more than one solution
+	var multiGrid Grid = hardGrid
+	multiGrid[0][0] = Blank
+	multiGrid[0][2] = Blank
+	multiGrid[0][4] = Blank
+
+	unique, err := IsUnique(&multiGrid)
+	if err != nil || unique {
+		errString := fmt.Sprintf("Failed multi-solution puzzle.  Returned: %v, %v", unique, err)
+		t.Error(errString)
+	}
+
+	var illGrid Grid = illegalGrid
+	if _, err := CountSolutions(&illGrid, 0); err == nil {
+		t.Error("Failed to catch illegal game config when counting")
+	} else {
+		fmt.Printf("Caught illegal config when counting: %v\n", err)
+	}
+}
+
+func TestSolutions(t *testing.T) {
+
+	var multiGrid Grid = hardGrid
+	multiGrid[0][0] = Blank
+	multiGrid[0][2] = Blank
+
+	total, _ := CountSolutions(&multiGrid, 0)
+
+	// Every solution must be distinct, keep the clues, and be a solved grid
+	seen := make(map[Grid]bool)
+	count, err := Solutions(&multiGrid, 0, func(soln Grid) bool {
+		if seen[soln] {
+			t.Error("Duplicate solution returned")
+		}
+		seen[soln] = true
+		for row := 0; row < GridSize; row++ {
+			for col := 0; col < GridSize; col++ {
+				if multiGrid[row][col] != Blank && multiGrid[row][col] != soln[row][col] {
+					t.Error(fmt.Sprintf("Solution changed clue at %d, %d", row, col))
+				}
+			}
+		}
+		var gp grid
+		if gp.load(&soln) != nil || !gp.checkGrid() {
+			t.Error("Returned solution is not a solved grid")
+		}
+		return true
+	})
+	if err != nil || count != total || count < 2 {
+		errString := fmt.Sprintf("Failed to enumerate solutions.  Returned: %d of %d, %v", count, total, err)
+		t.Error(errString)
+	} else {
+		fmt.Printf("\nEnumerated %d solutions\n", count)
+	}
+
+	// Early stop from the callback
+	count, _ = Solutions(&multiGrid, 0, func(Grid) bool {
+		return false
+	})
+	if count != 1 {
+		t.Error(fmt.Sprintf("Failed to stop early.  Returned: %d", count))
+	}
+}
+
+func TestSolveContext(t *testing.T) {
+
+	var blankGrid Grid
+
+	// A blank grid used to panic the solver.  Make sure it solves
+	if err := Solve(&blankGrid); err != nil {
+		t.Error(fmt.Sprintf("Failed blank puzzle.  Returned: %v", err))
+	}
+
+	// An already canceled context must stop the search
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var testGrid Grid = hardGrid
+	if err := SolveContext(ctx, &testGrid); err != ErrCanceled {
+		t.Error(fmt.Sprintf("Failed to cancel.  Returned: %v", err))
+	}
+	if testGrid != hardGrid {
+		t.Error("Canceled solve modified the puzzle")
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 0)
+	defer cancel()
+
+	var jGrid JsonGrid
+	jGrid.Solution = hardGrid
+	JsolveContext(ctx, &jGrid)
+	if jGrid.Status != ErrTimeout.Error() {
+		t.Error(fmt.Sprintf("Failed to time out.  Returned: %s", jGrid.Status))
+	} else {
+		fmt.Printf("\nCaught timeout: %s\n", jGrid.Status)
+	}
+}
+
+//  Corpus of minimal 17-clue puzzles for benchmarking.  Each row of the
+//  grid is written left to right, top to bottom with 0 for a blank cel.
+var clue17Corpus = []string{
+	"000000010400000000020000000000050407008000300001090000300400200050100000000806000",
+	"000000010400000000020000000000050604008000300001090000300400200050100000000807000",
+	"000000012000035000000600070700000300000400800100000000000120000080000040050000600",
+	"000000012003600000000007000410020000000500300700000600280000040000300500000000000",
+	"000000012008030000000000040120500000000004700060000000507000300000620000000100000",
+	"000000012040050000000009000070600400000100000000000050000087500601000300200000000",
+	"000000012050400000000000030700600400001000000000080000920000800000510700000003000",
+	"000000012300000060000040000900000500000001070020000000000350400001400800060000000",
+	"000000012400090000000000050070200000600000400000108000018000000000030700502000000",
+	"000000012500008000000700000600120000700000450000030000030000800000500700020000000"}
+
+//  The 17 clue corpus as grids.  Fails the test or benchmark if an entry
+//  can't be read
+func clue17Grids(tb testing.TB) []Grid {
+
+	var grids []Grid
+	for i, s := range clue17Corpus {
+		g, err := ParseString(s)
+		if err != nil {
+			tb.Fatal(fmt.Sprintf("Corpus entry %d not read.  Returned: %v", i, err))
+		}
+		grids = append(grids, g)
+	}
+	return grids
+}
+
+func benchmarkSolve(b *testing.B, puzzle Grid) {
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		testGrid := puzzle
+		if err := Solve(&testGrid); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSolveEasy(b *testing.B) {
+	benchmarkSolve(b, easyGrid)
+}
+
+func BenchmarkSolveMed(b *testing.B) {
+	benchmarkSolve(b, medGrid)
+}
+
+func BenchmarkSolveHard(b *testing.B) {
+	benchmarkSolve(b, hardGrid)
+}
+
+//  Solves the whole corpus once per iteration
+func BenchmarkSolve17Clue(b *testing.B) {
+
+	corpus := clue17Grids(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, puzzle := range corpus {
+			testGrid := puzzle
+			if err := Solve(&testGrid); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkIsUniqueHard(b *testing.B) {
+
+	testGrid := Grid(hardGrid)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if unique, err := IsUnique(&testGrid); err != nil || !unique {
+			b.Fatal(unique, err)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+
+	var celErr *CelError
+
+	// ooRangeGrid has 25 at row 4, col 3
+	testGrid := Grid(ooRangeGrid)
+	err := Solve(&testGrid)
+	if !errors.Is(err, ErrOutOfRange) || !errors.As(err, &celErr) || celErr.Row != 4 || celErr.Col != 3 {
+		t.Error(fmt.Sprintf("Wrong out of range error.  Returned: %v", err))
+	}
+
+	// illegalGrid repeats 5 in box 5
+	testGrid = illegalGrid
+	err = Solve(&testGrid)
+	if !errors.Is(err, ErrConflict) || !errors.As(err, &celErr) || celErr.Unit != (Unit{"box", 5}) {
+		t.Error(fmt.Sprintf("Wrong conflict error.  Returned: %v", err))
+	} else {
+		fmt.Printf("\nCaught conflict: %v\n", err)
+	}
+
+	testGrid = hardGrid
+	testGrid[8][8] = 2
+	if err = Solve(&testGrid); err != ErrUnsolvable {
+		t.Error(fmt.Sprintf("Wrong unsolvable error.  Returned: %v", err))
+	}
+
+	var jGrid JsonGrid
+	jGrid.Solution = illegalGrid
+	Jsolve(&jGrid)
+	if jGrid.Code != "conflict" {
+		t.Error(fmt.Sprintf("Wrong JSON error code.  Returned: %s", jGrid.Code))
+	}
+	jGrid.Solution = easyGrid
+	Jsolve(&jGrid)
+	if jGrid.Code != "" {
+		t.Error(fmt.Sprintf("Error code set on success.  Returned: %s", jGrid.Code))
+	}
+}
+
+func TestValidate(t *testing.T) {
+
+	// illegalGrid repeats 5 in box 5
+	testGrid := Grid(illegalGrid)
+	conflicts, err := Validate(&testGrid)
+	want := Conflict{Unit{"box", 5}, 5, [2]Cel{{3, 8}, {4, 6}}}
+	if err != nil || len(conflicts) != 1 || conflicts[0] != want {
+		t.Error(fmt.Sprintf("Wrong conflicts.  Returned: %v, %v", conflicts, err))
+	}
+
+	// Three 9s in row 0 clash as three pairs in row 0 and again in
+	// box 0.  The one in column 1 also clashes with the 9 at 4, 1
+	testGrid = easyGrid
+	testGrid[0][0] = 9
+	testGrid[0][1] = 9
+	conflicts, _ = Validate(&testGrid)
+	if len(conflicts) != 7 {
+		t.Error(fmt.Sprintf("Wrong number of conflicts.  Returned: %v", conflicts))
+	} else {
+		fmt.Printf("\nConflicts found: %v\n", conflicts)
+	}
+
+	testGrid = hardGrid
+	if conflicts, err = Validate(&testGrid); err != nil || len(conflicts) != 0 {
+		t.Error(fmt.Sprintf("Conflicts in a legal grid.  Returned: %v, %v", conflicts, err))
+	}
+
+	testGrid = ooRangeGrid
+	if _, err = Validate(&testGrid); !errors.Is(err, ErrOutOfRange) {
+		t.Error(fmt.Sprintf("Failed to catch ooRange.  Returned: %v", err))
+	}
+
+	var jGrid JsonGrid
+	jGrid.Solution = illegalGrid
+	Jsolve(&jGrid)
+	if len(jGrid.Conflicts) != 1 {
+		t.Error(fmt.Sprintf("Conflicts not returned in JSON.  Returned: %v", jGrid.Conflicts))
+	}
+}
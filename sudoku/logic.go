@@ -0,0 +1,955 @@
+//
+// Copyright 2020, 2021 Kenneth J. Gibson
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//
+// Logical solver.
+// Solves a puzzle the way a person would, by applying named techniques
+// to the pencil marks (candidate values) of each blank cel.  The easiest
+// technique that makes progress is always applied first, then the
+// solver starts again from the easiest.  Only when no technique applies
+// does it fall back to the backtracking search.
+//
+// Terminology used, beyond that in sudoku.go:
+//	Candidate:	A value still possible for a blank cel
+//	Placement:	Setting a cel to a value
+//	Elimination:	Removing a candidate from a cel
+//
+// Techniques that rely on a value having to go somewhere in a unit only
+// use the full units, with a cel for every value.  Extra regions with
+// fewer cels, and cages, still take part wherever holding a value at most
+// once is enough.
+//
+
+package sudoku
+
+import (
+	"context"
+	"fmt"
+)
+
+// Named solving techniques, in the order the logical solver tries them.
+// The order follows the usual difficulty ratings.
+type Technique int
+
+const (
+	NakedSingle Technique = iota
+	HiddenSingle
+	NakedPair
+	NakedTriple
+	HiddenPair
+	HiddenTriple
+	NakedQuad
+	HiddenQuad
+	PointingPair
+	BoxLineReduction
+	XWing
+	SimpleColoring
+	XYWing
+	Swordfish
+	XYZWing
+	Jellyfish
+	UniqueRectangle
+	Backtracking // Not a technique: search used once logic stalls
+	numTechniques
+)
+
+var techniqueNames = [numTechniques]string{
+	"Naked Single",
+	"Hidden Single",
+	"Naked Pair",
+	"Naked Triple",
+	"Hidden Pair",
+	"Hidden Triple",
+	"Naked Quad",
+	"Hidden Quad",
+	"Pointing Pair",
+	"Box/Line Reduction",
+	"X-Wing",
+	"Simple Coloring",
+	"XY-Wing",
+	"Swordfish",
+	"XYZ-Wing",
+	"Jellyfish",
+	"Unique Rectangle",
+	"Backtracking",
+}
+
+func (t Technique) String() string {
+	if t < 0 || t >= numTechniques {
+		return fmt.Sprintf("Technique(%d)", int(t))
+	}
+	return techniqueNames[t]
+}
+
+// Techniques are sent by name in JSON
+func (t Technique) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// Summary of a logical solve
+type LogicResult struct {
+	Counts      map[Technique]int `json:"counts"`      // Times each technique was applied
+	Hardest     Technique         `json:"hardest"`     // Hardest technique applied
+	SearchNodes int               `json:"searchNodes"` // Search nodes once logic stalled.  0 if not needed
+}
+
+//
+//  A single deduction found by a technique.  Not applied until the
+//  solver decides to use it.
+//
+type step struct {
+	tech   Technique
+	placed []celVal // Values placed
+	elims  []celVal // Candidates eliminated
+	cause  []int    // Cels that justify the deduction
+	units  []int    // Units the deduction works within, if any
+	digits valSet   // Values the deduction is about
+}
+
+type celVal struct {
+	idx int
+	val CelVal
+}
+
+//
+//  Internal state for the logical solver.  Extends the solver grid with
+//  the candidates for each blank cel.  Unlike the search, which works out
+//  candidates from the unit masks, eliminations made by the techniques
+//  must be remembered, so they are kept per cel.
+//
+type logicGrid struct {
+	grid
+	cands  []valSet // Candidates for each blank cel.  Empty once filled
+	unique bool     // Puzzle known to have one solution.  Needed by UniqueRectangle
+}
+
+// Set of cel positions within a unit, or of unit numbers within a
+// group of units, held as a bitmask
+type posSet uint32
+
+// Order the techniques are tried in.  Each finder returns the first
+// deduction it can make, or nil.
+var logicOrder = []struct {
+	tech Technique
+	find func(lg *logicGrid) *step
+}{
+	{NakedSingle, (*logicGrid).findNakedSingle},
+	{HiddenSingle, (*logicGrid).findHiddenSingle},
+	{NakedPair, func(lg *logicGrid) *step { return lg.findNakedSubset(NakedPair, 2) }},
+	{NakedTriple, func(lg *logicGrid) *step { return lg.findNakedSubset(NakedTriple, 3) }},
+	{HiddenPair, func(lg *logicGrid) *step { return lg.findHiddenSubset(HiddenPair, 2) }},
+	{HiddenTriple, func(lg *logicGrid) *step { return lg.findHiddenSubset(HiddenTriple, 3) }},
+	{NakedQuad, func(lg *logicGrid) *step { return lg.findNakedSubset(NakedQuad, 4) }},
+	{HiddenQuad, func(lg *logicGrid) *step { return lg.findHiddenSubset(HiddenQuad, 4) }},
+	{PointingPair, func(lg *logicGrid) *step { return lg.findLockedCandidates(PointingPair) }},
+	{BoxLineReduction, func(lg *logicGrid) *step { return lg.findLockedCandidates(BoxLineReduction) }},
+	{XWing, func(lg *logicGrid) *step { return lg.findFish(XWing, 2) }},
+	{SimpleColoring, (*logicGrid).findSimpleColoring},
+	{XYWing, (*logicGrid).findXYWing},
+	{Swordfish, func(lg *logicGrid) *step { return lg.findFish(Swordfish, 3) }},
+	{XYZWing, (*logicGrid).findXYZWing},
+	{Jellyfish, func(lg *logicGrid) *step { return lg.findFish(Jellyfish, 4) }},
+	{UniqueRectangle, (*logicGrid).findUniqueRectangle},
+}
+
+// Kinds of unit, by unit number.  See the engine geometry in sudoku.go.
+// Jigsaw regions take the place of boxes, and Hyper Sudoku windows are
+// the same shape as them
+func (geo *geometry) isBox(unit int) bool {
+	kind := geo.units[unit].Kind
+	return kind == "box" || kind == "region" || kind == "window"
+}
+
+// Fill in the candidates of every blank cel from the unit masks
+func (lg *logicGrid) initCands() {
+	lg.cands = make([]valSet, lg.geo.numCels)
+	for idx := 0; idx < lg.geo.numCels; idx++ {
+		if lg.value[idx] == Blank {
+			lg.cands[idx] = lg.options(idx)
+		}
+	}
+}
+
+// Place a value and remove it from the candidates of the cel's peers.
+// The other cels of a cage or constraint lose any candidates the sum or
+// the constraint now rules out
+func (lg *logicGrid) setValue(idx int, val CelVal) {
+	lg.place(idx, val)
+	lg.cands[idx] = 0
+	for _, peer := range lg.geo.peers[idx] {
+		lg.cands[peer] &^= valBit(val)
+	}
+	if lg.geo.sums != nil {
+		for _, unit := range lg.geo.celUnits[idx] {
+			if lg.geo.sums[unit] == 0 {
+				continue
+			}
+			for _, other := range lg.geo.unitCels[unit] {
+				if lg.value[other] == Blank {
+					lg.cands[other] &= lg.options(other)
+				}
+			}
+		}
+	}
+	if lg.geo.celConstraints != nil {
+		for _, ref := range lg.geo.celConstraints[idx] {
+			c := lg.geo.constraints[ref.con]
+			for _, other := range c.cels() {
+				if lg.value[other] == Blank {
+					lg.cands[other] &= lg.options(other)
+				}
+			}
+		}
+	}
+}
+
+func (lg *logicGrid) apply(st *step) {
+	for _, p := range st.placed {
+		if lg.value[p.idx] == Blank {
+			lg.setValue(p.idx, p.val)
+		}
+	}
+	for _, e := range st.elims {
+		lg.cands[e.idx] &^= valBit(e.val)
+	}
+}
+
+// Positions within a unit where a value is still a candidate
+func (lg *logicGrid) positions(unit int, val CelVal) posSet {
+	var ps posSet
+	for pos, idx := range lg.geo.unitCels[unit] {
+		if lg.cands[idx].has(val) {
+			ps |= 1 << uint(pos)
+		}
+	}
+	return ps
+}
+
+//  True if the candidates can no longer lead to a solution: a blank cel
+//  with no candidates, or a unit with nowhere left for a missing value.
+
+func (lg *logicGrid) broken() bool {
+	for idx := 0; idx < lg.geo.numCels; idx++ {
+		if lg.value[idx] == Blank && lg.cands[idx] == 0 {
+			return true
+		}
+	}
+	for unit := range lg.geo.units {
+		if !lg.geo.full(unit) {
+			continue
+		}
+		for missing := lg.geo.allVals &^ lg.used[unit]; missing != 0; missing &^= valBit(missing.first()) {
+			if lg.positions(unit, missing.first()) == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Find the easiest deduction available.  Returns nil if logic is stalled
+
+func (lg *logicGrid) nextStep() *step {
+	for _, t := range logicOrder {
+		if t.tech == UniqueRectangle && !lg.unique {
+			continue
+		}
+		if st := t.find(lg); st != nil {
+			return st
+		}
+	}
+	return nil
+}
+
+//  Once logic is stalled, finish the puzzle with the backtracking search.
+//  The search only uses the values placed so far, not the eliminations,
+//  so it finds the same solution.  Returns the placements as a single step.
+
+func (lg *logicGrid) searchStep(ctx context.Context) (*step, error) {
+
+	soln := make([]CelVal, lg.geo.numCels)
+	var found bool
+
+	_, err := lg.recursiveSolve(ctx, func(gp *grid) bool {
+		copy(soln, gp.value)
+		found = true
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrUnsolvable
+	}
+
+	st := &step{tech: Backtracking}
+	for idx := 0; idx < lg.geo.numCels; idx++ {
+		if lg.value[idx] == Blank {
+			st.placed = append(st.placed, celVal{idx, soln[idx]})
+		}
+	}
+	return st, nil
+}
+
+//  Internal driver for the logical solver.  Applies the easiest deduction
+//  available until the puzzle is solved, calling record with each step.
+//  Assumes the grid is loaded and the candidates built.
+
+func (lg *logicGrid) solveLogical(ctx context.Context, record func(st *step)) error {
+
+	for lg.filled < lg.geo.numCels {
+		if err := ctxError(ctx); err != nil {
+			return err
+		}
+
+		st := lg.nextStep()
+		if st == nil {
+			var err error
+			if st, err = lg.searchStep(ctx); err != nil {
+				return err
+			}
+		}
+
+		lg.apply(st)
+		record(st)
+		if lg.broken() {
+			return ErrUnsolvable
+		}
+	}
+	return nil
+}
+
+//  Internal front end for the logical entry points.  Checks the puzzle
+//  the same way Solve does, and also counts its solutions since the
+//  uniqueness techniques are only sound for a puzzle with one solution.
+
+func (lg *logicGrid) prepareLogical(ctx context.Context, configP celGrid) error {
+
+	count, err := countSolutions(ctx, configP, 2)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrUnsolvable
+	}
+	lg.unique = count == 1
+
+	// Already checked above, so cannot fail
+	lg.load(configP)
+	lg.initCands()
+	return nil
+}
+
+//  Public entry point for solving a puzzle with the logical solver.
+//  Populates the Grid with the solution like Solve, and returns the same
+//  errors.  Also returns which techniques were needed.
+
+func SolveLogical(configP *Grid) (LogicResult, error) {
+
+	return SolveLogicalContext(context.Background(), configP)
+}
+
+//  Same as SolveLogical, but gives up when the context is canceled or its
+//  deadline passes.
+
+func SolveLogicalContext(ctx context.Context, configP *Grid) (LogicResult, error) {
+
+	var lg logicGrid
+
+	result, err := lg.logicalResult(ctx, configP)
+	if err != nil {
+		return result, err
+	}
+	lg.store(configP)
+	return result, nil
+}
+
+//  Solve either kind of grid logically, and tally the techniques used.
+//  The solution is left in lg; the caller's grid is not modified.
+
+func (lg *logicGrid) logicalResult(ctx context.Context, configP celGrid) (LogicResult, error) {
+
+	result := LogicResult{Counts: make(map[Technique]int)}
+
+	if err := lg.prepareLogical(ctx, configP); err != nil {
+		return result, err
+	}
+
+	err := lg.solveLogical(ctx, func(st *step) {
+		result.Counts[st.tech]++
+		if st.tech > result.Hardest {
+			result.Hardest = st.tech
+		}
+	})
+	if err != nil {
+		return result, err
+	}
+
+	result.SearchNodes = lg.nodes
+	return result, nil
+}
+
+//  Call fn with each combination of n of the items, in order.
+//  Stops and returns false if fn returns false.  The combo slice is
+//  reused between calls.
+
+func forEachCombo(items []int, n int, fn func(combo []int) bool) bool {
+
+	combo := make([]int, 0, n)
+
+	var next func(start int) bool
+	next = func(start int) bool {
+		if len(combo) == n {
+			return fn(combo)
+		}
+		for i := start; i <= len(items)-(n-len(combo)); i++ {
+			combo = append(combo, items[i])
+			if !next(i + 1) {
+				return false
+			}
+			combo = combo[:len(combo)-1]
+		}
+		return true
+	}
+	return next(0)
+}
+
+// Naked Single: a cel with only one candidate left
+
+func (lg *logicGrid) findNakedSingle() *step {
+	for idx := 0; idx < lg.geo.numCels; idx++ {
+		if lg.value[idx] == Blank && lg.cands[idx].count() == 1 {
+			val := lg.cands[idx].first()
+			return &step{tech: NakedSingle, placed: []celVal{{idx, val}}, digits: valBit(val)}
+		}
+	}
+	return nil
+}
+
+// Hidden Single: a value with only one place left in a unit
+
+func (lg *logicGrid) findHiddenSingle() *step {
+	for unit := range lg.geo.units {
+		if !lg.geo.full(unit) {
+			continue
+		}
+		for missing := lg.geo.allVals &^ lg.used[unit]; missing != 0; missing &^= valBit(missing.first()) {
+			val := missing.first()
+			if ps := lg.positions(unit, val); ps != 0 && ps&(ps-1) == 0 {
+				idx := lg.geo.unitCels[unit][posList(ps)[0]]
+				return &step{tech: HiddenSingle, placed: []celVal{{idx, val}},
+					units: []int{unit}, digits: valBit(val)}
+			}
+		}
+	}
+	return nil
+}
+
+// Positions in a set, in order
+func posList(ps posSet) []int {
+	var list []int
+	for pos := 0; ps != 0; pos++ {
+		if ps&1 != 0 {
+			list = append(list, pos)
+		}
+		ps >>= 1
+	}
+	return list
+}
+
+//  Naked Pair/Triple/Quad: n cels in a unit that between them only have
+//  n candidates.  Those values must go in those cels, so can be removed
+//  from the rest of the unit.
+
+func (lg *logicGrid) findNakedSubset(tech Technique, n int) *step {
+
+	var found *step
+
+	for unit := 0; unit < len(lg.geo.units) && found == nil; unit++ {
+		var cels []int
+		for _, idx := range lg.geo.unitCels[unit] {
+			if cnt := lg.cands[idx].count(); cnt >= 2 && cnt <= n {
+				cels = append(cels, idx)
+			}
+		}
+
+		forEachCombo(cels, n, func(combo []int) bool {
+			var vals valSet
+			for _, idx := range combo {
+				vals |= lg.cands[idx]
+			}
+			if vals.count() != n {
+				return true
+			}
+
+			var elims []celVal
+			for _, idx := range lg.geo.unitCels[unit] {
+				if lg.value[idx] != Blank || containsCel(combo, idx) {
+					continue
+				}
+				elims = appendElims(elims, idx, lg.cands[idx]&vals)
+			}
+			if elims == nil {
+				return true
+			}
+			found = &step{tech: tech, elims: elims, cause: copyCels(combo),
+				units: []int{unit}, digits: vals}
+			return false
+		})
+	}
+	return found
+}
+
+//  Hidden Pair/Triple/Quad: n values that between them only have n places
+//  left in a unit.  Those cels must hold those values, so any other
+//  candidates can be removed from them.
+
+func (lg *logicGrid) findHiddenSubset(tech Technique, n int) *step {
+
+	var found *step
+
+	for unit := 0; unit < len(lg.geo.units) && found == nil; unit++ {
+		if !lg.geo.full(unit) {
+			continue
+		}
+		var vals []int
+		for missing := lg.geo.allVals &^ lg.used[unit]; missing != 0; missing &^= valBit(missing.first()) {
+			val := missing.first()
+			if cnt := len(posList(lg.positions(unit, val))); cnt >= 2 && cnt <= n {
+				vals = append(vals, int(val))
+			}
+		}
+
+		forEachCombo(vals, n, func(combo []int) bool {
+			var ps posSet
+			var digits valSet
+			for _, val := range combo {
+				ps |= lg.positions(unit, CelVal(val))
+				digits |= valBit(CelVal(val))
+			}
+			if len(posList(ps)) != n {
+				return true
+			}
+
+			var cels []int
+			var elims []celVal
+			for _, pos := range posList(ps) {
+				idx := lg.geo.unitCels[unit][pos]
+				cels = append(cels, idx)
+				elims = appendElims(elims, idx, lg.cands[idx]&^digits)
+			}
+			if elims == nil {
+				return true
+			}
+			found = &step{tech: tech, elims: elims, cause: cels,
+				units: []int{unit}, digits: digits}
+			return false
+		})
+	}
+	return found
+}
+
+//  Pointing Pair: the candidates for a value in a box all lie in one row
+//  or column, so the value can be removed from the rest of that line.
+//  Box/Line Reduction: the reverse.  The candidates for a value in a row
+//  or column all lie in one box, so it can be removed from the rest of
+//  the box.  Diagonals and extra regions count as lines and windows as
+//  boxes.  The first unit must be full, so that the value has to go in
+//  it somewhere.
+
+func (lg *logicGrid) findLockedCandidates(tech Technique) *step {
+
+	for a := range lg.geo.units {
+		if lg.geo.isBox(a) != (tech == PointingPair) || !lg.geo.full(a) {
+			continue
+		}
+		for b := range lg.geo.units {
+			if lg.geo.isBox(b) == lg.geo.isBox(a) {
+				continue
+			}
+
+			for missing := lg.geo.allVals &^ lg.used[a]; missing != 0; missing &^= valBit(missing.first()) {
+				val := missing.first()
+
+				// Every candidate in unit a must also be in unit b
+				var cause []int
+				inside := true
+				for _, idx := range lg.geo.unitCels[a] {
+					if lg.cands[idx].has(val) {
+						if !lg.geo.celInUnit(idx, b) {
+							inside = false
+							break
+						}
+						cause = append(cause, idx)
+					}
+				}
+				if !inside || len(cause) < 2 {
+					continue
+				}
+
+				var elims []celVal
+				for _, idx := range lg.geo.unitCels[b] {
+					if !lg.geo.celInUnit(idx, a) {
+						elims = appendElims(elims, idx, lg.cands[idx]&valBit(val))
+					}
+				}
+				if elims != nil {
+					return &step{tech: tech, elims: elims, cause: cause,
+						units: []int{a, b}, digits: valBit(val)}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+//  X-Wing, Swordfish and Jellyfish: n rows where a value's candidates all
+//  lie in the same n columns.  The value must go in those columns within
+//  these rows, so can be removed from the rest of the columns.  Also
+//  tried with rows and columns swapped.
+
+func (lg *logicGrid) findFish(tech Technique, n int) *step {
+
+	var found *step
+
+	for val := MinVal; val <= lg.geo.maxVal && found == nil; val++ {
+		for _, baseOff := range []int{0, lg.geo.size} {
+			coverOff := lg.geo.size - baseOff
+
+			// For a row, the position of a cel is its column, and the
+			// other way round, so positions index the cover lines
+			var bases []int
+			cover := make([]posSet, lg.geo.size)
+			for line := 0; line < lg.geo.size; line++ {
+				ps := lg.positions(baseOff+line, val)
+				if cnt := len(posList(ps)); cnt >= 2 && cnt <= n {
+					bases = append(bases, line)
+					cover[line] = ps
+				}
+			}
+
+			forEachCombo(bases, n, func(combo []int) bool {
+				var lines, covers posSet
+				for _, line := range combo {
+					lines |= 1 << uint(line)
+					covers |= cover[line]
+				}
+				if len(posList(covers)) != n {
+					return true
+				}
+
+				// Base lines first, then the cover lines
+				var elims []celVal
+				var cause, units []int
+				for _, line := range combo {
+					units = append(units, baseOff+line)
+				}
+				for _, line := range posList(covers) {
+					units = append(units, coverOff+line)
+					for pos, idx := range lg.geo.unitCels[coverOff+line] {
+						if lines&(1<<uint(pos)) != 0 {
+							if lg.cands[idx].has(val) {
+								cause = append(cause, idx)
+							}
+							continue
+						}
+						elims = appendElims(elims, idx, lg.cands[idx]&valBit(val))
+					}
+				}
+				if elims == nil {
+					return true
+				}
+				found = &step{tech: tech, elims: elims, cause: cause,
+					units: units, digits: valBit(val)}
+				return false
+			})
+			if found != nil {
+				break
+			}
+		}
+	}
+	return found
+}
+
+//  XY-Wing: a pivot cel with candidates XY sees one cel with XZ and another
+//  with YZ.  Whichever value the pivot takes, one of the pincers must be Z,
+//  so Z can be removed from any cel that sees both pincers.
+
+func (lg *logicGrid) findXYWing() *step {
+
+	for pivot := 0; pivot < lg.geo.numCels; pivot++ {
+		pv := lg.cands[pivot]
+		if pv.count() != 2 {
+			continue
+		}
+		for _, p1 := range lg.geo.peers[pivot] {
+			c1 := lg.cands[p1]
+			if c1.count() != 2 || (c1&pv).count() != 1 {
+				continue
+			}
+			z := c1 &^ pv
+			for _, p2 := range lg.geo.peers[pivot] {
+				if p2 == p1 || lg.cands[p2] != (pv^c1) {
+					continue
+				}
+				elims := lg.elimsSeenBy(z, []int{p1, p2}, []int{pivot})
+				if elims != nil {
+					return &step{tech: XYWing, elims: elims, cause: []int{pivot, p1, p2},
+						digits: pv | c1}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+//  XYZ-Wing: a pivot cel with candidates XYZ sees one cel with XZ and
+//  another with YZ.  One of the three must be Z, so Z can be removed from
+//  any cel that sees all three.
+
+func (lg *logicGrid) findXYZWing() *step {
+
+	for pivot := 0; pivot < lg.geo.numCels; pivot++ {
+		pv := lg.cands[pivot]
+		if pv.count() != 3 {
+			continue
+		}
+		for _, p1 := range lg.geo.peers[pivot] {
+			c1 := lg.cands[p1]
+			if c1.count() != 2 || c1&^pv != 0 {
+				continue
+			}
+			for _, p2 := range lg.geo.peers[pivot] {
+				c2 := lg.cands[p2]
+				if p2 <= p1 || c2.count() != 2 || c2&^pv != 0 || c1|c2 != pv {
+					continue
+				}
+				z := c1 & c2
+				elims := lg.elimsSeenBy(z, []int{pivot, p1, p2}, nil)
+				if elims != nil {
+					return &step{tech: XYZWing, elims: elims, cause: []int{pivot, p1, p2},
+						digits: pv}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+//  Eliminations of a single value from every cel that sees all of the
+//  given cels, other than the cels listed in skip
+
+func (lg *logicGrid) elimsSeenBy(val valSet, cels []int, skip []int) []celVal {
+
+	var elims []celVal
+	for _, idx := range lg.geo.peers[cels[0]] {
+		if lg.cands[idx]&val == 0 || containsCel(cels, idx) || containsCel(skip, idx) {
+			continue
+		}
+		seesAll := true
+		for _, c := range cels[1:] {
+			if !lg.geo.sees[idx][c] {
+				seesAll = false
+				break
+			}
+		}
+		if seesAll {
+			elims = appendElims(elims, idx, val)
+		}
+	}
+	return elims
+}
+
+//  Simple Coloring: for one value, chain together the units where it has
+//  only two places (conjugate pairs), coloring the cels alternately.
+//  Exactly one color holds the value.  If two cels of the same color see
+//  each other, that color is false.  Any other cel that sees both colors
+//  cannot hold the value.
+
+func (lg *logicGrid) findSimpleColoring() *step {
+
+	for val := MinVal; val <= lg.geo.maxVal; val++ {
+		bit := valBit(val)
+
+		// Conjugate pair links for this value
+		links := make([][]int, lg.geo.numCels)
+		for unit := range lg.geo.units {
+			ps := posList(lg.positions(unit, val))
+			if len(ps) == 2 && lg.geo.full(unit) {
+				a, b := lg.geo.unitCels[unit][ps[0]], lg.geo.unitCels[unit][ps[1]]
+				links[a] = append(links[a], b)
+				links[b] = append(links[b], a)
+			}
+		}
+
+		color := make([]int, lg.geo.numCels) // 0 uncolored, else 1 or 2
+		for start := 0; start < lg.geo.numCels; start++ {
+			if len(links[start]) == 0 || color[start] != 0 {
+				continue
+			}
+
+			// Color this chain breadth first
+			chain := []int{start}
+			color[start] = 1
+			for i := 0; i < len(chain); i++ {
+				for _, next := range links[chain[i]] {
+					if color[next] == 0 {
+						color[next] = 3 - color[chain[i]]
+						chain = append(chain, next)
+					}
+				}
+			}
+			if len(chain) < 3 {
+				continue
+			}
+
+			// Color wrap: same color twice in a unit
+			for _, a := range chain {
+				for _, b := range chain {
+					if a < b && color[a] == color[b] && lg.geo.sees[a][b] {
+						var elims []celVal
+						for _, idx := range chain {
+							if color[idx] == color[a] {
+								elims = appendElims(elims, idx, bit)
+							}
+						}
+						return &step{tech: SimpleColoring, elims: elims, cause: chain, digits: bit}
+					}
+				}
+			}
+
+			// Color trap: an outside cel that sees both colors
+			var elims []celVal
+			for idx := 0; idx < lg.geo.numCels; idx++ {
+				if !lg.cands[idx].has(val) || containsCel(chain, idx) {
+					continue
+				}
+				var seen [3]bool
+				for _, c := range chain {
+					if lg.geo.sees[idx][c] {
+						seen[color[c]] = true
+					}
+				}
+				if seen[1] && seen[2] {
+					elims = appendElims(elims, idx, bit)
+				}
+			}
+			if elims != nil {
+				return &step{tech: SimpleColoring, elims: elims, cause: chain, digits: bit}
+			}
+		}
+	}
+	return nil
+}
+
+//  Unique Rectangle (type 1): four cels at the corners of a rectangle in
+//  two boxes, three of which only have the same two candidates.  If the
+//  fourth also only had those two, the two values could be swapped and the
+//  puzzle would not be unique.  So they can be removed from the fourth.
+//  Only sound for a puzzle with one solution, and only for corners in no
+//  variant region and with no chess variant or constraint, since any of
+//  them might not allow the swap.
+
+func (lg *logicGrid) findUniqueRectangle() *step {
+
+	if lg.geo.moves != nil || lg.geo.constraints != nil {
+		return nil
+	}
+
+	size := lg.geo.size
+	for r1 := 0; r1 < size; r1++ {
+		for r2 := r1 + 1; r2 < size; r2++ {
+			for c1 := 0; c1 < size; c1++ {
+				for c2 := c1 + 1; c2 < size; c2++ {
+					corners := []int{r1*size + c1, r1*size + c2, r2*size + c1, r2*size + c2}
+					if st := lg.checkRectangle(corners); st != nil {
+						return st
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (lg *logicGrid) checkRectangle(corners []int) *step {
+
+	boxes := make(map[int]bool)
+	for _, idx := range corners {
+		if lg.value[idx] != Blank || len(lg.geo.celUnits[idx]) != 3 {
+			return nil
+		}
+		boxes[lg.geo.celUnits[idx][2]] = true
+	}
+	if len(boxes) != 2 {
+		return nil
+	}
+
+	// Find the pair shared by three bi-value corners, and the odd one out
+	for odd, oddIdx := range corners {
+		var pair valSet
+		matched := true
+		for i, idx := range corners {
+			if i == odd {
+				continue
+			}
+			if pair == 0 {
+				pair = lg.cands[idx]
+			}
+			if lg.cands[idx] != pair || pair.count() != 2 {
+				matched = false
+				break
+			}
+		}
+		if matched && lg.cands[oddIdx]&pair == pair && lg.cands[oddIdx] != pair {
+			elims := appendElims(nil, oddIdx, pair)
+			return &step{tech: UniqueRectangle, elims: elims, cause: corners, digits: pair}
+		}
+	}
+	return nil
+}
+
+// Small helpers for building steps
+
+func containsCel(cels []int, idx int) bool {
+	for _, c := range cels {
+		if c == idx {
+			return true
+		}
+	}
+	return false
+}
+
+func copyCels(cels []int) []int {
+	return append([]int(nil), cels...)
+}
+
+func (geo *geometry) celInUnit(idx int, unit int) bool {
+	for _, u := range geo.celUnits[idx] {
+		if u == unit {
+			return true
+		}
+	}
+	return false
+}
+
+// Add one elimination per value in vals
+func appendElims(elims []celVal, idx int, vals valSet) []celVal {
+	for ; vals != 0; vals &^= valBit(vals.first()) {
+		elims = append(elims, celVal{idx, vals.first()})
+	}
+	return elims
+}
@@ -0,0 +1,378 @@
+// "go test" program for the logical solver.  Checks every deduction
+// made against the known solution of the puzzle.
+
+package sudoku
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+//  Run the logical solver, checking each step against the solution.
+//  Returns the number of times each technique was used.
+
+func checkLogic(t *testing.T, name string, puzzle Grid) map[Technique]int {
+
+	soln := puzzle
+	if err := Solve(&soln); err != nil {
+		t.Error(fmt.Sprintf("%s: %v", name, err))
+		return nil
+	}
+	solnVal := func(idx int) CelVal {
+		return soln[idx/GridSize][idx%GridSize]
+	}
+
+	var lg logicGrid
+	if err := lg.prepareLogical(context.Background(), &puzzle); err != nil {
+		t.Error(fmt.Sprintf("%s: %v", name, err))
+		return nil
+	}
+
+	counts := make(map[Technique]int)
+	err := lg.solveLogical(context.Background(), func(st *step) {
+		counts[st.tech]++
+		for _, p := range st.placed {
+			if p.val != solnVal(p.idx) {
+				t.Error(fmt.Sprintf("%s: %v placed %d at cel %d, %d", name, st.tech, p.val,
+					p.idx/GridSize, p.idx%GridSize))
+			}
+		}
+		for _, e := range st.elims {
+			if e.val == solnVal(e.idx) {
+				t.Error(fmt.Sprintf("%s: %v eliminated solution %d at cel %d, %d", name, st.tech,
+					e.val, e.idx/GridSize, e.idx%GridSize))
+			}
+		}
+	})
+	if err != nil {
+		t.Error(fmt.Sprintf("%s: %v", name, err))
+	}
+	return counts
+}
+
+func TestLogicSteps(t *testing.T) {
+
+	total := make(map[Technique]int)
+
+	puzzles := map[string]Grid{"easy": easyGrid, "medium": medGrid, "hard": hardGrid}
+	for i, puzzle := range clue17Grids(t) {
+		puzzles[fmt.Sprintf("17 clue %d", i)] = puzzle
+	}
+	for name, puzzle := range puzzles {
+		for tech, n := range checkLogic(t, name, puzzle) {
+			total[tech] += n
+		}
+	}
+
+	fmt.Printf("\nTechniques used:\n")
+	for tech := NakedSingle; tech < numTechniques; tech++ {
+		fmt.Printf("%-20v %d\n", tech, total[tech])
+	}
+}
+
+func TestSolveLogical(t *testing.T) {
+
+	testGrid := Grid(easyGrid)
+	result, err := SolveLogical(&testGrid)
+	if err != nil || result.Hardest > HiddenSingle || result.SearchNodes != 0 {
+		t.Error(fmt.Sprintf("Failed easy puzzle.  Returned: %+v, %v", result, err))
+	}
+
+	soln := Grid(hardGrid)
+	Solve(&soln)
+	testGrid = hardGrid
+	if result, err = SolveLogical(&testGrid); err != nil || testGrid != soln {
+		t.Error(fmt.Sprintf("Failed hard puzzle.  Returned: %v", err))
+	} else {
+		fmt.Printf("\nHard puzzle needed %v, %d search nodes\n", result.Hardest, result.SearchNodes)
+	}
+
+	testGrid = illegalGrid
+	if _, err = SolveLogical(&testGrid); err == nil {
+		t.Error("Failed to catch illegal game config")
+	}
+}
+
+//  Synthetic candidate states for testing each technique on its own.
+//  Each starts from a blank grid where every cel has every candidate.
+
+func blankLogic() *logicGrid {
+	var lg logicGrid
+	lg.init(classic)
+	lg.initCands()
+	return &lg
+}
+
+func at(row int, col int) int {
+	return row*GridSize + col
+}
+
+// Remove a value from the cels given as row, col pairs
+func (lg *logicGrid) dropCands(val CelVal, rowCols ...int) {
+	for i := 0; i < len(rowCols); i += 2 {
+		lg.cands[at(rowCols[i], rowCols[i+1])] &^= valBit(val)
+	}
+}
+
+// Remove a value from every cel of a row other than the listed columns
+func (lg *logicGrid) keepInRow(val CelVal, row int, cols ...int) {
+	for col := 0; col < GridSize; col++ {
+		if !containsCel(cols, col) {
+			lg.dropCands(val, row, col)
+		}
+	}
+}
+
+// Set the candidates of a cel
+func (lg *logicGrid) setCands(row int, col int, vals ...CelVal) {
+	var vs valSet
+	for _, val := range vals {
+		vs |= valBit(val)
+	}
+	lg.cands[at(row, col)] = vs
+}
+
+// Eliminations of the values from the listed columns, other than in
+// the listed rows
+func colElims(cols []int, skipRows []int, vals ...CelVal) []celVal {
+	var elims []celVal
+	for _, col := range cols {
+		for row := 0; row < GridSize; row++ {
+			if !containsCel(skipRows, row) {
+				for _, val := range vals {
+					elims = append(elims, celVal{at(row, col), val})
+				}
+			}
+		}
+	}
+	return elims
+}
+
+// Eliminations of the values from row 0, other than in the listed columns
+func row0Elims(skipCols []int, vals ...CelVal) []celVal {
+	var elims []celVal
+	for col := 0; col < GridSize; col++ {
+		if !containsCel(skipCols, col) {
+			for _, val := range vals {
+				elims = append(elims, celVal{at(0, col), val})
+			}
+		}
+	}
+	return elims
+}
+
+// Eliminations of the values from the listed columns of row 0
+func cels0Elims(cols []int, vals ...CelVal) []celVal {
+	var elims []celVal
+	for col := 0; col < GridSize; col++ {
+		if containsCel(cols, col) {
+			for _, val := range vals {
+				elims = append(elims, celVal{at(0, col), val})
+			}
+		}
+	}
+	return elims
+}
+
+type techCase struct {
+	tech  Technique
+	setup func(lg *logicGrid)
+	want  []celVal
+}
+
+var techCases = []techCase{
+	{NakedPair, func(lg *logicGrid) {
+		lg.setCands(0, 0, 1, 2)
+		lg.setCands(0, 1, 1, 2)
+	}, row0Elims([]int{0, 1}, 1, 2)},
+	{NakedQuad, func(lg *logicGrid) {
+		lg.setCands(0, 0, 1, 2)
+		lg.setCands(0, 1, 2, 3)
+		lg.setCands(0, 3, 3, 4)
+		lg.setCands(0, 5, 1, 4)
+	}, row0Elims([]int{0, 1, 3, 5}, 1, 2, 3, 4)},
+	{HiddenPair, func(lg *logicGrid) {
+		for _, val := range []CelVal{1, 2} {
+			lg.keepInRow(val, 0, 0, 1)
+		}
+	}, cels0Elims([]int{0, 1}, 3, 4, 5, 6, 7, 8, 9)},
+	{HiddenQuad, func(lg *logicGrid) {
+		lg.keepInRow(1, 0, 0, 1)
+		lg.keepInRow(2, 0, 1, 2)
+		lg.keepInRow(3, 0, 2, 4)
+		lg.keepInRow(4, 0, 0, 4)
+	}, cels0Elims([]int{0, 1, 2, 4}, 5, 6, 7, 8, 9)},
+	{PointingPair, func(lg *logicGrid) {
+		lg.dropCands(5, 0, 2, 1, 0, 1, 1, 1, 2, 2, 0, 2, 1, 2, 2)
+	}, []celVal{{at(0, 3), 5}, {at(0, 4), 5}, {at(0, 5), 5}, {at(0, 6), 5}, {at(0, 7), 5}, {at(0, 8), 5}}},
+	{BoxLineReduction, func(lg *logicGrid) {
+		lg.keepInRow(5, 0, 0, 1)
+	}, []celVal{{at(1, 0), 5}, {at(1, 1), 5}, {at(1, 2), 5}, {at(2, 0), 5}, {at(2, 1), 5}, {at(2, 2), 5}}},
+	{XWing, func(lg *logicGrid) {
+		lg.keepInRow(1, 0, 2, 6)
+		lg.keepInRow(1, 4, 2, 6)
+	}, colElims([]int{2, 6}, []int{0, 4}, 1)},
+	{Swordfish, func(lg *logicGrid) {
+		lg.keepInRow(2, 0, 1, 4)
+		lg.keepInRow(2, 3, 4, 7)
+		lg.keepInRow(2, 6, 1, 7)
+	}, colElims([]int{1, 4, 7}, []int{0, 3, 6}, 2)},
+	{Jellyfish, func(lg *logicGrid) {
+		lg.keepInRow(3, 0, 0, 3)
+		lg.keepInRow(3, 2, 3, 5)
+		lg.keepInRow(3, 4, 5, 8)
+		lg.keepInRow(3, 7, 0, 8)
+	}, colElims([]int{0, 3, 5, 8}, []int{0, 2, 4, 7}, 3)},
+	{XYWing, func(lg *logicGrid) {
+		lg.setCands(0, 0, 1, 2)
+		lg.setCands(0, 4, 1, 3)
+		lg.setCands(4, 0, 2, 3)
+	}, []celVal{{at(4, 4), 3}}},
+	{XYZWing, func(lg *logicGrid) {
+		lg.setCands(0, 0, 1, 2, 3)
+		lg.setCands(0, 4, 1, 3)
+		lg.setCands(1, 1, 2, 3)
+	}, []celVal{{at(0, 1), 3}, {at(0, 2), 3}}},
+	{SimpleColoring, func(lg *logicGrid) {
+		// Chain 0,0 - 2,2 - 6,2 - 8,0 through box 0, column 2 and box 6
+		lg.dropCands(7, 0, 1, 0, 2, 1, 0, 1, 1, 1, 2, 2, 0, 2, 1)
+		lg.dropCands(7, 6, 0, 6, 1, 7, 0, 7, 1, 7, 2, 8, 1, 8, 2)
+		lg.dropCands(7, 3, 2, 4, 2, 5, 2)
+	}, []celVal{{at(3, 0), 7}, {at(4, 0), 7}, {at(5, 0), 7}}},
+	{UniqueRectangle, func(lg *logicGrid) {
+		lg.setCands(0, 0, 1, 2)
+		lg.setCands(0, 4, 1, 2)
+		lg.setCands(1, 0, 1, 2)
+		lg.setCands(1, 4, 1, 2, 5)
+	}, []celVal{{at(1, 4), 1}, {at(1, 4), 2}}},
+}
+
+func TestTechniques(t *testing.T) {
+
+	for _, tc := range techCases {
+		lg := blankLogic()
+		tc.setup(lg)
+
+		var st *step
+		for _, t := range logicOrder {
+			if t.tech == tc.tech {
+				st = t.find(lg)
+			}
+		}
+		if st == nil || st.tech != tc.tech || len(st.elims) == 0 {
+			t.Error(fmt.Sprintf("%v not found", tc.tech))
+			continue
+		}
+		if !sameElims(st.elims, tc.want) {
+			t.Error(fmt.Sprintf("%v eliminated %v, want %v", tc.tech, st.elims, tc.want))
+		}
+
+	}
+}
+
+func sameElims(got []celVal, want []celVal) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	seen := make(map[celVal]bool)
+	for _, e := range got {
+		seen[e] = true
+	}
+	for _, e := range want {
+		if !seen[e] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSolveSteps(t *testing.T) {
+
+	testGrid := Grid(hardGrid)
+	steps, err := SolveSteps(&testGrid)
+	if err != nil || len(steps) == 0 {
+		t.Error(fmt.Sprintf("Failed hard puzzle.  Returned: %v", err))
+		return
+	}
+
+	// Replaying the placements must rebuild the solution
+	replay := Grid(hardGrid)
+	for _, st := range steps {
+		if st.Explanation == "" || (st.Placed == nil && st.Eliminated == nil) {
+			t.Error(fmt.Sprintf("Empty step: %+v", st))
+		}
+		for _, p := range st.Placed {
+			replay[p.Row][p.Col] = p.Value
+		}
+	}
+	if replay != testGrid {
+		t.Error("Replayed steps do not match the solution")
+	}
+
+	fmt.Printf("\nHard puzzle solved in %d steps.  First and hardest steps:\n", len(steps))
+	fmt.Printf("%s\n", steps[0].Explanation)
+	for _, st := range steps {
+		if st.Technique > HiddenSingle {
+			fmt.Printf("%s\n", st.Explanation)
+		}
+	}
+
+	var jSteps JsonSteps
+	jSteps.Solution = illegalGrid
+	Jsteps(&jSteps)
+	if jSteps.Code != "conflict" || jSteps.Steps != nil {
+		t.Error(fmt.Sprintf("Failed to catch illegal config.  Returned: %s", jSteps.Status))
+	}
+}
+
+func TestExplanations(t *testing.T) {
+
+	for _, tc := range techCases {
+		lg := blankLogic()
+		tc.setup(lg)
+		for _, t := range logicOrder {
+			if t.tech == tc.tech {
+				fmt.Printf("%s\n", t.find(lg).explain(lg.geo))
+			}
+		}
+	}
+}
+
+func TestGrade(t *testing.T) {
+
+	corpus := clue17Grids(t)
+	var last int
+	for _, tc := range []struct {
+		name   string
+		puzzle Grid
+		want   Difficulty
+	}{
+		{"easy", easyGrid, Easy},
+		{"medium", corpus[4], Medium},
+		{"hard", hardGrid, Hard},
+		{"extreme", corpus[8], Extreme},
+	} {
+		grade, err := Grade(&tc.puzzle)
+		if err != nil || grade.Difficulty != tc.want || grade.Score < last {
+			t.Error(fmt.Sprintf("Wrong grade for %s puzzle.  Returned: %v %d, %v", tc.name,
+				grade.Difficulty, grade.Score, err))
+		} else {
+			fmt.Printf("%s puzzle graded %v, score %d, hardest %v\n", tc.name,
+				grade.Difficulty, grade.Score, grade.Hardest)
+		}
+		last = grade.Score
+	}
+
+	// Expert techniques are rare in real puzzles
+	if g := gradeOf(LogicResult{Counts: map[Technique]int{Swordfish: 1}}); g.Difficulty != Expert {
+		t.Error(fmt.Sprintf("Swordfish not graded expert.  Returned: %v", g.Difficulty))
+	}
+
+	var jGrade JsonGrade
+	jGrade.Puzzle = illegalGrid
+	Jgrade(&jGrade)
+	if jGrade.Code != "conflict" {
+		t.Error(fmt.Sprintf("Failed to catch illegal config.  Returned: %s", jGrade.Status))
+	}
+}
@@ -0,0 +1,142 @@
+//
+// Copyright 2020, 2021 Kenneth J. Gibson
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//
+// Checking a player's entries.
+// Solve treats every non-blank cel as a clue, so it cannot tell the
+// givens of a puzzle from the values a player has entered.  Here the two
+// are kept apart: the givens are solved on their own, and each entry is
+// compared with that solution.
+//
+// Terminology used, beyond that in sudoku.go:
+//	Given:	A clue of the original puzzle
+//	Entry:	A value the player has entered in a blank cel
+//
+
+package sudoku
+
+import (
+	"context"
+	"fmt"
+)
+
+// Result of checking a player's entries
+type MistakeReport struct {
+	Mistakes    []Cel `json:"mistakes"`    // Entries that disagree with the solution
+	Completable bool  `json:"completable"` // True if the entries can still lead to the solution
+}
+
+//  Public entry point for checking a player's entries against the puzzle.
+//  givensP holds the original puzzle, and entriesP the player's grid.
+//  The player's grid may repeat the givens or leave them blank, but may
+//  not change them.  Neither Grid is modified.
+//
+//  Returns ErrOutOfRange for an illegal value and ErrGivenChanged for an
+//  entry over a given, as a *CelError.  The givens must have exactly one
+//  solution: returns the same errors as Solve for a bad puzzle, and
+//  ErrNotUnique for one with several solutions.
+
+func Mistakes(givensP, entriesP *Grid) (MistakeReport, error) {
+
+	return MistakesContext(context.Background(), givensP, entriesP)
+}
+
+//  Same as Mistakes, but gives up when the context is canceled or its
+//  deadline passes.
+
+func MistakesContext(ctx context.Context, givensP, entriesP *Grid) (MistakeReport, error) {
+
+	var report MistakeReport
+	var soln Grid
+
+	for row := 0; row < GridSize; row++ {
+		for col := 0; col < GridSize; col++ {
+			given, entry := givensP[row][col], entriesP[row][col]
+			if !entry.IsValid() {
+				return report, &CelError{Err: ErrOutOfRange, Row: row, Col: col}
+			}
+			if given != Blank && entry != Blank && entry != given {
+				return report, &CelError{Err: ErrGivenChanged, Row: row, Col: col}
+			}
+		}
+	}
+
+	// Keep the first solution, and look for a second
+	count, err := DefaultSolver.Solutions(ctx, givensP, 2, func(s Grid) bool {
+		if soln == (Grid{}) {
+			soln = s
+		}
+		return true
+	})
+	switch {
+	case err != nil:
+		return report, err
+	case count == 0:
+		return report, ErrUnsolvable
+	case count > 1:
+		return report, ErrNotUnique
+	}
+
+	for row := 0; row < GridSize; row++ {
+		for col := 0; col < GridSize; col++ {
+			entry := entriesP[row][col]
+			if givensP[row][col] == Blank && entry != Blank && entry != soln[row][col] {
+				report.Mistakes = append(report.Mistakes, Cel{row, col})
+			}
+		}
+	}
+
+	// Any completion of the player's grid also solves the givens, and
+	// there is only one solution to those.  So the grid can be completed
+	// exactly when every entry agrees with it.
+	report.Completable = len(report.Mistakes) == 0
+	return report, nil
+}
+
+// Exported struct for marshaling a check of a player's entries to/from
+// JSON.  The original puzzle is sent in Givens and the player's grid in
+// Entries.  Neither is changed.
+type JsonMistakes struct {
+	Givens  Grid   `json:"givens"`
+	Entries Grid   `json:"entries"`
+	Status  string `json:"status"`
+	Code    string `json:"code,omitempty"` // Machine-readable error code.  See ErrorCode
+	MistakeReport
+}
+
+// JSON version of Mistakes.  Copies status into the JsonMistakes struct
+// the same way Jsolve does for a JsonGrid.
+
+func Jmistakes(jMistakesP *JsonMistakes) {
+
+	JmistakesContext(context.Background(), jMistakesP)
+}
+
+// Same as Jmistakes, but gives up when the context is canceled or
+// its deadline passes.
+
+func JmistakesContext(ctx context.Context, jMistakesP *JsonMistakes) {
+
+	report, err := MistakesContext(ctx, &jMistakesP.Givens, &jMistakesP.Entries)
+	jMistakesP.MistakeReport = report
+	if err != nil {
+		jMistakesP.Status = fmt.Sprintf("%v", err)
+		jMistakesP.Code = ErrorCode(err)
+		return
+	}
+	jMistakesP.Status = fmt.Sprintf("Success")
+	jMistakesP.Code = ""
+}
@@ -0,0 +1,82 @@
+// "go test" program for checking a player's entries
+
+package sudoku
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestMistakes(t *testing.T) {
+
+	var givens Grid = hardGrid
+	var soln Grid = hardGrid
+	Solve(&soln)
+
+	// Correct entries, with the givens left blank
+	var entries Grid
+	entries[0][1], entries[4][4] = soln[0][1], soln[4][4]
+	report, err := Mistakes(&givens, &entries)
+	if err != nil || len(report.Mistakes) != 0 || !report.Completable {
+		t.Error(fmt.Sprintf("Correct entries reported wrong.  Returned: %v %v", report, err))
+	}
+
+	// Two wrong entries, over a grid that repeats the givens
+	entries = givens
+	var wrong []Cel
+	for idx := 0; idx < classic.numCels && len(wrong) < 2; idx++ {
+		row, col := idx/GridSize, idx%GridSize
+		if givens[row][col] == Blank {
+			entries[row][col] = soln[row][col]%MaxVal + 1
+			wrong = append(wrong, Cel{row, col})
+		}
+	}
+	report, err = Mistakes(&givens, &entries)
+	if err != nil || fmt.Sprint(report.Mistakes) != fmt.Sprint(wrong) || report.Completable {
+		t.Error(fmt.Sprintf("Wrong entries not reported.  Returned: %v %v", report, err))
+	}
+
+	// A completed grid
+	report, err = Mistakes(&givens, &soln)
+	if err != nil || len(report.Mistakes) != 0 || !report.Completable {
+		t.Error(fmt.Sprintf("Solution reported wrong.  Returned: %v %v", report, err))
+	}
+}
+
+func TestMistakesErrors(t *testing.T) {
+
+	var givens Grid = hardGrid
+	var entries Grid
+
+	entries[0][0] = MaxVal + 1
+	if _, err := Mistakes(&givens, &entries); !errors.Is(err, ErrOutOfRange) {
+		t.Error(fmt.Sprintf("Expected ErrOutOfRange.  Returned: %v", err))
+	}
+
+	// Find a given and change it
+	entries = Grid{}
+	for idx := 0; idx < classic.numCels; idx++ {
+		row, col := idx/GridSize, idx%GridSize
+		if givens[row][col] != Blank {
+			entries[row][col] = givens[row][col]%MaxVal + 1
+			break
+		}
+	}
+	var celErr *CelError
+	if _, err := Mistakes(&givens, &entries); !errors.Is(err, ErrGivenChanged) || !errors.As(err, &celErr) {
+		t.Error(fmt.Sprintf("Expected ErrGivenChanged.  Returned: %v", err))
+	}
+
+	var blank Grid
+	if _, err := Mistakes(&blank, &blank); !errors.Is(err, ErrNotUnique) {
+		t.Error(fmt.Sprintf("Expected ErrNotUnique.  Returned: %v", err))
+	}
+
+	var jMistakes JsonMistakes
+	jMistakes.Givens = illegalGrid
+	Jmistakes(&jMistakes)
+	if jMistakes.Code != "conflict" {
+		t.Error(fmt.Sprintf("Failed to catch illegal config.  Returned: %s", jMistakes.Status))
+	}
+}
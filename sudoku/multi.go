@@ -0,0 +1,376 @@
+//
+// Copyright 2020, 2021 Kenneth J. Gibson
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//
+// Multi-grid puzzles.
+// Samurai, Twodoku and Butterfly puzzles are standard grids laid out on
+// a larger board so that some of their boxes overlap.  A cel shared by
+// two grids holds one value, which must suit the units of both.
+//
+// The engine solves the whole board as one puzzle.  Its geometry numbers
+// every board cel covered by a grid once, and gives each grid its own
+// rows, columns and boxes over those cels, so a shared box is a unit of
+// each grid it belongs to.  The board position of each cel takes the
+// place of its row and column, and the MultiGrid reads and writes the
+// grids holding it.
+//
+
+package sudoku
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Where the top left cel of each grid of a layout sits on the board
+var multiLayouts = map[string][]Cel{
+	"twodoku":   {{0, 0}, {6, 6}},
+	"butterfly": {{0, 0}, {0, 3}, {3, 0}, {3, 3}},
+	"samurai":   {{0, 0}, {0, 12}, {6, 6}, {12, 0}, {12, 12}},
+}
+
+// Several standard grids solved together.  Layout is "samurai" (five
+// grids, the middle one sharing a corner box with each of the others),
+// "twodoku" (two grids sharing a corner box) or "butterfly" (four grids
+// in a 12x12 square).  Grids holds each grid of the layout, top to
+// bottom and left to right, with 0 representing a blank cel.  A shared
+// cel may be given in any of the grids holding it.
+type MultiGrid struct {
+	Layout string
+	Grids  []Grid
+}
+
+// A cel of one grid of a multi-grid puzzle
+type gridCel struct {
+	grid     int
+	row, col int
+}
+
+// Grids of a layout holding a board cel, by the cel's position in each
+func holding(offsets []Cel, row, col int) []gridCel {
+	var held []gridCel
+	for g, off := range offsets {
+		r, c := row-off.Row, col-off.Col
+		if r >= 0 && r < GridSize && c >= 0 && c < GridSize {
+			held = append(held, gridCel{g, r, c})
+		}
+	}
+	return held
+}
+
+// Geometries already built, by layout name
+var multiGeometries = struct {
+	sync.Mutex
+	byLayout map[string]*geometry
+}{byLayout: make(map[string]*geometry)}
+
+//  Geometry for a layout, building it the first time it is asked for.
+//  Returns ErrBadLayout for an unknown layout.
+
+func multiGeometryOf(name string) (*geometry, error) {
+
+	offsets, ok := multiLayouts[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrBadLayout, name)
+	}
+
+	multiGeometries.Lock()
+	defer multiGeometries.Unlock()
+
+	geo, ok := multiGeometries.byLayout[name]
+	if !ok {
+		geo = newMultiGeometry(offsets)
+		multiGeometries.byLayout[name] = geo
+	}
+	return geo, nil
+}
+
+//  Build the geometry of a board with standard grids at the given
+//  offsets.  Board cels are numbered top to bottom and left to right.
+//  The units of each grid follow on from those of the grid before, and
+//  are named as in a standard grid.
+
+func newMultiGeometry(offsets []Cel) *geometry {
+
+	geo := &geometry{
+		shape:   classic.shape,
+		size:    classic.size,
+		maxVal:  classic.maxVal,
+		allVals: classic.allVals,
+	}
+
+	at := make(map[Cel]int)
+	for _, off := range offsets {
+		for row := 0; row < GridSize; row++ {
+			for col := 0; col < GridSize; col++ {
+				cel := Cel{off.Row + row, off.Col + col}
+				if _, ok := at[cel]; !ok {
+					at[cel] = 0
+					geo.board = append(geo.board, cel)
+				}
+			}
+		}
+	}
+	sort.Slice(geo.board, func(i, j int) bool {
+		a, b := geo.board[i], geo.board[j]
+		return a.Row < b.Row || (a.Row == b.Row && a.Col < b.Col)
+	})
+	for idx, cel := range geo.board {
+		at[cel] = idx
+	}
+	geo.numCels = len(geo.board)
+
+	for _, off := range offsets {
+		for unit, cels := range classic.unitCels {
+			geo.units = append(geo.units, classic.units[unit])
+			onBoard := make([]int, len(cels))
+			for i, idx := range cels {
+				cel := classic.celOf(idx)
+				onBoard[i] = at[Cel{off.Row + cel.Row, off.Col + cel.Col}]
+			}
+			geo.unitCels = append(geo.unitCels, onBoard)
+		}
+	}
+
+	geo.link()
+	return geo
+}
+
+func (mp *MultiGrid) geo() (*geometry, error) {
+
+	geo, err := multiGeometryOf(mp.Layout)
+	if err != nil {
+		return nil, err
+	}
+	offsets := multiLayouts[mp.Layout]
+	if len(mp.Grids) != len(offsets) {
+		return nil, fmt.Errorf("%w: %s has %d grids, not %d", ErrBadLayout, mp.Layout, len(offsets), len(mp.Grids))
+	}
+
+	// Grids sharing a cel must not give it different values
+	for _, cel := range geo.board {
+		var given CelVal
+		for _, gc := range holding(offsets, cel.Row, cel.Col) {
+			val := mp.Grids[gc.grid][gc.row][gc.col]
+			if val != Blank && given != Blank && val != given {
+				return nil, &CelError{Err: ErrConflict, Row: cel.Row, Col: cel.Col}
+			}
+			if val != Blank {
+				given = val
+			}
+		}
+	}
+	return geo, nil
+}
+
+// The value of a board cel, from whichever grid gives it
+func (mp *MultiGrid) cel(row, col int) CelVal {
+	for _, gc := range holding(multiLayouts[mp.Layout], row, col) {
+		if val := mp.Grids[gc.grid][gc.row][gc.col]; val != Blank {
+			return val
+		}
+	}
+	return Blank
+}
+
+// Set a board cel in every grid holding it
+func (mp *MultiGrid) setCel(row, col int, val CelVal) {
+	for _, gc := range holding(multiLayouts[mp.Layout], row, col) {
+		mp.Grids[gc.grid][gc.row][gc.col] = val
+	}
+}
+
+// Blank puzzle of the same layout
+func (mp *MultiGrid) blank() MultiGrid {
+	return MultiGrid{Layout: mp.Layout, Grids: make([]Grid, len(mp.Grids))}
+}
+
+//  Public entry point for solving a multi-grid puzzle.  Same as Solve,
+//  solving every grid at once, and also returns ErrBadLayout for an
+//  unknown layout or the wrong number of grids.  Errors at a cel give
+//  its position on the board.  See GridErrors.
+
+func SolveMulti(configP *MultiGrid) error {
+
+	return SolveMultiWith(context.Background(), DefaultSolver, configP)
+}
+
+//  Same as SolveMulti, using the given solver backend and giving up when
+//  the context is canceled or its deadline passes.
+
+func SolveMultiWith(ctx context.Context, solver Solver, configP *MultiGrid) error {
+
+	var soln MultiGrid
+
+	// Stop at the first solution
+	count, err := solver.MultiSolutions(ctx, configP, 1, func(s MultiGrid) bool {
+		soln = s
+		return false
+	})
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrUnsolvable
+	}
+
+	*configP = soln
+	return nil
+}
+
+//  Same as CountSolutionsWith for a multi-grid puzzle
+
+func CountSolutionsMultiWith(ctx context.Context, solver Solver, configP *MultiGrid, limit int) (int, error) {
+
+	return solver.MultiSolutions(ctx, configP, limit, func(MultiGrid) bool {
+		return true
+	})
+}
+
+//  Split an error from solving a multi-grid puzzle by grid.  A grid
+//  whose own values, with any shared cels given by its neighbours, are
+//  out of range or clash is given that error, in its own rows and
+//  columns.  Otherwise an error at a cel goes to each grid holding it,
+//  and any other error to every grid.  Returns nil for each grid the
+//  error does not concern.
+
+func (mp *MultiGrid) GridErrors(err error) []error {
+
+	errs := make([]error, len(mp.Grids))
+	offsets, ok := multiLayouts[mp.Layout]
+	if err == nil || !ok || len(offsets) != len(mp.Grids) {
+		for g := range errs {
+			errs[g] = err
+		}
+		return errs
+	}
+
+	found := false
+	for g, off := range offsets {
+		var view Grid
+		for row := range view {
+			for col := range view[row] {
+				view[row][col] = mp.cel(off.Row+row, off.Col+col)
+			}
+		}
+		var gp grid
+		if errs[g] = gp.load(&view); errs[g] != nil {
+			found = true
+		}
+	}
+	if found {
+		return errs
+	}
+
+	celErr, ok := err.(*CelError)
+	if !ok {
+		for g := range errs {
+			errs[g] = err
+		}
+		return errs
+	}
+	for _, gc := range holding(offsets, celErr.Row, celErr.Col) {
+		errs[gc.grid] = &CelError{Err: celErr.Err, Row: gc.row, Col: gc.col}
+	}
+	return errs
+}
+
+// Exported struct for marshaling a multi-grid puzzle to/from JSON.
+// Each grid's givens are sent in its Solution, which is replaced by
+// its part of the joint solution.  Status, Code and Unique report on
+// the puzzle as a whole, and the Status and Code of each grid report
+// any error found in that grid.
+type JsonMulti struct {
+	Layout string        `json:"layout"` // "samurai", "twodoku" or "butterfly"
+	Grids  []JsonSubGrid `json:"grids"`
+	Solver string        `json:"solver,omitempty"` // Solver backend, as for JsonGrid
+	Unique bool          `json:"unique"`
+	Status string        `json:"status"`
+	Code   string        `json:"code,omitempty"` // Machine-readable error code.  See ErrorCode
+}
+
+// One grid of a JsonMulti
+type JsonSubGrid struct {
+	Solution Grid   `json:"solution"`
+	Status   string `json:"status"`
+	Code     string `json:"code,omitempty"`
+}
+
+// JSON version of SolveMulti.  Copies status into the JsonMulti struct
+// the same way Jsolve does for a JsonGrid.
+
+func Jmulti(jMultiP *JsonMulti) {
+
+	JmultiContext(context.Background(), jMultiP)
+}
+
+// Same as Jmulti, but gives up when the context is canceled or its
+// deadline passes.  Uses the solver backend named in the Solver field.
+
+func JmultiContext(ctx context.Context, jMultiP *JsonMulti) {
+
+	puzzle := MultiGrid{Layout: jMultiP.Layout}
+	for _, sub := range jMultiP.Grids {
+		puzzle.Grids = append(puzzle.Grids, sub.Solution)
+	}
+
+	fail := func(err error) {
+		jMultiP.Unique = false
+		jMultiP.Status = fmt.Sprintf("%v", err)
+		jMultiP.Code = ErrorCode(err)
+		for g, gErr := range puzzle.GridErrors(err) {
+			sub := &jMultiP.Grids[g]
+			sub.Status, sub.Code = "", ""
+			if gErr != nil {
+				sub.Status = fmt.Sprintf("%v", gErr)
+				sub.Code = ErrorCode(gErr)
+			}
+		}
+	}
+
+	solver, err := SolverByName(jMultiP.Solver)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	soln := MultiGrid{Layout: puzzle.Layout, Grids: append([]Grid(nil), puzzle.Grids...)}
+	if err := SolveMultiWith(ctx, solver, &soln); err != nil {
+		fail(err)
+		return
+	}
+
+	// Solve succeeded, so the puzzle is valid and can only fail here
+	// if interrupted
+	count, err := CountSolutionsMultiWith(ctx, solver, &puzzle, 2)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	for g := range jMultiP.Grids {
+		sub := &jMultiP.Grids[g]
+		sub.Solution = soln.Grids[g]
+		sub.Status = fmt.Sprintf("Success")
+		sub.Code = ""
+	}
+	jMultiP.Status = fmt.Sprintf("Success")
+	jMultiP.Code = ""
+	jMultiP.Unique = count == 1
+}
@@ -0,0 +1,164 @@
+// "go test" program for multi-grid puzzles
+
+package sudoku
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// True if every grid is solved and the grids agree on every shared cel
+func solvedMulti(mg *MultiGrid) bool {
+	offsets := multiLayouts[mg.Layout]
+	board := make(map[Cel]CelVal)
+	for g := range mg.Grids {
+		sg := SizedGridOf(&mg.Grids[g])
+		if !solvedSized(&sg) {
+			return false
+		}
+		for row := range mg.Grids[g] {
+			for col, val := range mg.Grids[g][row] {
+				cel := Cel{offsets[g].Row + row, offsets[g].Col + col}
+				if seen, ok := board[cel]; ok && seen != val {
+					return false
+				}
+				board[cel] = val
+			}
+		}
+	}
+	return true
+}
+
+// Copy of a multi-grid puzzle, so a solve leaves the original alone
+func copyMulti(mg MultiGrid) MultiGrid {
+	return MultiGrid{Layout: mg.Layout, Grids: append([]Grid(nil), mg.Grids...)}
+}
+
+// A solved puzzle of each layout, with the middle box of every grid
+// cleared.  The rest of each grid fixes the box, so the puzzle is unique
+func multiPuzzle(t *testing.T, layout string) (MultiGrid, MultiGrid) {
+
+	soln := MultiGrid{Layout: layout, Grids: make([]Grid, len(multiLayouts[layout]))}
+	if err := SolveMultiWith(context.Background(), DancingLinks, &soln); err != nil || !solvedMulti(&soln) {
+		t.Fatal(fmt.Sprintf("%s: blank board not solved.  Returned: %v", layout, err))
+	}
+	puzzle := copyMulti(soln)
+	for g := range puzzle.Grids {
+		for row := 3; row < 6; row++ {
+			for col := 3; col < 6; col++ {
+				puzzle.Grids[g][row][col] = Blank
+			}
+		}
+	}
+	return puzzle, soln
+}
+
+func TestMulti(t *testing.T) {
+
+	for layout := range multiLayouts {
+		puzzle, soln := multiPuzzle(t, layout)
+
+		for _, solver := range []Solver{Backtrack, DancingLinks} {
+			// Blank boards fill with every grid agreeing
+			blank := soln.blank()
+			if err := SolveMultiWith(context.Background(), solver, &blank); err != nil || !solvedMulti(&blank) {
+				t.Error(fmt.Sprintf("%T: blank %s not solved.  Returned: %v", solver, layout, err))
+			}
+
+			if n, err := CountSolutionsMultiWith(context.Background(), solver, &puzzle, 2); err != nil || n != 1 {
+				t.Error(fmt.Sprintf("%T: %s not unique.  Returned: %d, %v", solver, layout, n, err))
+			}
+			config := copyMulti(puzzle)
+			if err := SolveMultiWith(context.Background(), solver, &config); err != nil || fmt.Sprint(config) != fmt.Sprint(soln) {
+				t.Error(fmt.Sprintf("%T: %s not solved.  Returned: %v", solver, layout, err))
+			}
+		}
+	}
+
+	// A given in one grid fills the shared cel of the other
+	twodoku := MultiGrid{Layout: "twodoku", Grids: make([]Grid, 2)}
+	twodoku.Grids[0][8][8] = 4
+	if err := SolveMulti(&twodoku); err != nil || twodoku.Grids[1][2][2] != 4 || !solvedMulti(&twodoku) {
+		t.Error(fmt.Sprintf("Shared given not kept.  Returned: %v", err))
+	}
+}
+
+func TestMultiErrors(t *testing.T) {
+
+	for name, mg := range map[string]MultiGrid{
+		"unknown":      {Layout: "triplet", Grids: make([]Grid, 3)},
+		"grids":        {Layout: "samurai", Grids: make([]Grid, 4)},
+		"empty layout": {Grids: make([]Grid, 1)},
+	} {
+		if err := SolveMulti(&mg); !errors.Is(err, ErrBadLayout) {
+			t.Error(fmt.Sprintf("%s: expected ErrBadLayout.  Returned: %v", name, err))
+		}
+	}
+
+	// Two grids giving a shared cel different values.  The clash is at
+	// 6, 6 on the board, which is 0, 0 of the second grid
+	mg := MultiGrid{Layout: "twodoku", Grids: make([]Grid, 2)}
+	mg.Grids[0][6][6], mg.Grids[1][0][0] = 1, 2
+	err := SolveMulti(&mg)
+	errs := mg.GridErrors(err)
+	var e0, e1 *CelError
+	if !errors.Is(err, ErrConflict) || !errors.As(errs[0], &e0) || !errors.As(errs[1], &e1) ||
+		e0.Row != 6 || e0.Col != 6 || e1.Row != 0 || e1.Col != 0 {
+		t.Error(fmt.Sprintf("Shared clash not reported.  Returned: %v, %v", err, errs))
+	}
+
+	// A shared given that clashes with a row of the second grid only
+	mg.Grids[1][0][0], mg.Grids[1][0][5] = Blank, 1
+	err = SolveMulti(&mg)
+	errs = mg.GridErrors(err)
+	if !errors.Is(err, ErrConflict) || errs[0] != nil || !errors.As(errs[1], &e1) || e1.Unit.Kind == "" {
+		t.Error(fmt.Sprintf("Clash in the second grid not reported.  Returned: %v, %v", err, errs))
+	}
+
+	// A puzzle that only fails as a whole fails in every grid
+	mg = MultiGrid{Layout: "twodoku", Grids: make([]Grid, 2)}
+	errs = mg.GridErrors(ErrUnsolvable)
+	if errs[0] != ErrUnsolvable || errs[1] != ErrUnsolvable {
+		t.Error(fmt.Sprintf("Unsolvable not reported in every grid.  Returned: %v", errs))
+	}
+}
+
+func TestJmulti(t *testing.T) {
+
+	puzzle, soln := multiPuzzle(t, "samurai")
+	var grids []map[string]interface{}
+	for _, g := range puzzle.Grids {
+		grids = append(grids, map[string]interface{}{"solution": g})
+	}
+	body, _ := json.Marshal(map[string]interface{}{"layout": "samurai", "grids": grids, "solver": "dlx"})
+	var jMulti JsonMulti
+	if err := json.Unmarshal(body, &jMulti); err != nil || len(jMulti.Grids) != 5 {
+		t.Fatal(fmt.Sprintf("Multi-grid not decoded.  Returned: %v", err))
+	}
+
+	Jmulti(&jMulti)
+	if jMulti.Code != "" || !jMulti.Unique {
+		t.Error(fmt.Sprintf("Samurai not solved.  Returned: %s", jMulti.Status))
+	}
+	for g, sub := range jMulti.Grids {
+		if sub.Code != "" || sub.Solution != soln.Grids[g] {
+			t.Error(fmt.Sprintf("Grid %d not solved.  Returned: %s", g, sub.Status))
+		}
+	}
+
+	// Out of range in the bottom right grid only
+	jMulti.Grids[4].Solution[8][8] = 10
+	Jmulti(&jMulti)
+	if jMulti.Code != "out_of_range" || jMulti.Grids[4].Code != "out_of_range" || jMulti.Grids[2].Code != "" {
+		t.Error(fmt.Sprintf("Bad value not reported in its grid.  Returned: %s, %+v", jMulti.Status, jMulti.Grids))
+	}
+
+	jMulti.Layout = "quad"
+	Jmulti(&jMulti)
+	if jMulti.Code != "bad_layout" || jMulti.Grids[0].Code != "bad_layout" {
+		t.Error(fmt.Sprintf("Failed to catch a bad layout.  Returned: %s", jMulti.Status))
+	}
+}
@@ -0,0 +1,213 @@
+//
+// Copyright 2020, 2021 Kenneth J. Gibson
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//
+// Marks between neighbouring cels.
+// Kropki dots, XV marks and greater-than signs each relate the values of
+// two cels side by side or one above the other.  Every mark becomes a
+// constraint over its two cels that allows the pairs of values meeting
+// its relation.
+//
+// In negative mode the marks given are all there are, so every other
+// pair of neighbours must not meet any relation of that family.  Each
+// of those pairs becomes a constraint too, allowing the pairs of values
+// that meet none of them.
+//
+
+package sudoku
+
+import (
+	"fmt"
+)
+
+// A mark between two neighbouring cels.  For a greater-than sign, the
+// value in A is the larger.
+type Pair struct {
+	A Cel `json:"a"`
+	B Cel `json:"b"`
+}
+
+// True if values a and b can sit in the cels of a pair, in that order
+type relation func(a, b int) bool
+
+func consecutive(a, b int) bool { return a-b == 1 || b-a == 1 }
+func double(a, b int) bool      { return a == 2*b || b == 2*a }
+func sumsTo10(a, b int) bool    { return a+b == 10 }
+func sumsTo5(a, b int) bool     { return a+b == 5 }
+func greater(a, b int) bool     { return a > b }
+
+func noKropki(a, b int) bool       { return !consecutive(a, b) && !double(a, b) }
+func noXV(a, b int) bool           { return !sumsTo10(a, b) && !sumsTo5(a, b) }
+func notConsecutive(a, b int) bool { return !consecutive(a, b) }
+
+//  Check the marks against a grid of the given side and build a
+//  constraint for each, plus one for each unmarked pair of neighbours
+//  in negative mode.  Returns ErrBadConstraints describing the first
+//  problem found.
+
+func (cs *Constraints) buildPairs(size int) ([]constraint, error) {
+
+	var built []constraint
+	marked := map[string]map[[2]int]bool{"kropki": {}, "xv": {}}
+
+	kinds := []struct {
+		kind   string
+		pairs  []Pair
+		rel    relation
+		family string
+	}{
+		{"white", cs.White, consecutive, "kropki"},
+		{"black", cs.Black, double, "kropki"},
+		{"x", cs.X, sumsTo10, "xv"},
+		{"v", cs.V, sumsTo5, "xv"},
+		{"greater", cs.Greater, greater, ""},
+	}
+	for _, k := range kinds {
+		if len(k.pairs) > 0 && !possiblePair(k.rel, size) {
+			return nil, fmt.Errorf("%w: %s marks are not possible on a grid of size %d", ErrBadConstraints, k.kind, size)
+		}
+		for i, pair := range k.pairs {
+			for _, cel := range []Cel{pair.A, pair.B} {
+				if cel.Row < 0 || cel.Row >= size || cel.Col < 0 || cel.Col >= size {
+					return nil, fmt.Errorf("%w: %s %d cel %d, %d is off the grid", ErrBadConstraints, k.kind, i, cel.Row, cel.Col)
+				}
+			}
+			if d := abs(pair.A.Row-pair.B.Row) + abs(pair.A.Col-pair.B.Col); d != 1 {
+				return nil, fmt.Errorf("%w: %s %d cels are not neighbours", ErrBadConstraints, k.kind, i)
+			}
+			both := celIndexes([]Cel{pair.A, pair.B}, size)
+			built = append(built, newPairRule(Unit{k.kind, i}, both, k.rel, size))
+			if k.family != "" {
+				marked[k.family][neighbours(both)] = true
+			}
+		}
+	}
+
+	negative := []struct {
+		on     bool
+		family string
+		rel    relation
+	}{
+		{cs.NegativeKropki, "kropki", noKropki},
+		{cs.NegativeXV, "xv", noXV},
+	}
+	for _, neg := range negative {
+		if neg.on {
+			built = append(built, neighbourRules(size, neg.family, neg.rel, marked[neg.family])...)
+		}
+	}
+	return built, nil
+}
+
+//  A constraint for each pair of neighbours not in skip, numbered in cel
+//  order, with the pair to the right of a cel before the pair below it
+
+func neighbourRules(size int, kind string, rel relation, skip map[[2]int]bool) []constraint {
+
+	var built []constraint
+	for idx := 0; idx < size*size; idx++ {
+		for _, next := range []int{idx + 1, idx + size} {
+			if next >= size*size || (next == idx+1 && next%size == 0) {
+				continue
+			}
+			if both := []int{idx, next}; !skip[neighbours(both)] {
+				built = append(built, newPairRule(Unit{kind, len(built)}, both, rel, size))
+			}
+		}
+	}
+	return built
+}
+
+// Key for a pair of neighbouring cels in either order
+func neighbours(both []int) [2]int {
+	if both[0] > both[1] {
+		return [2]int{both[1], both[0]}
+	}
+	return [2]int{both[0], both[1]}
+}
+
+// True if two different values up to size meet the relation
+func possiblePair(rel relation, size int) bool {
+	for a := 1; a <= size; a++ {
+		for b := 1; b <= size; b++ {
+			if a != b && rel(a, b) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Two neighbouring cels whose values must meet a relation.  Neighbours
+// share a row or column, so their values always differ.  The values each
+// cel can take are worked out once, when the rule is built.
+type pairRule struct {
+	id   Unit
+	both []int
+	open [2]valSet   // Values of each cel with the other blank
+	with [2][]valSet // Values of each cel by the value of the other
+}
+
+func newPairRule(id Unit, both []int, rel relation, size int) *pairRule {
+
+	p := &pairRule{id: id, both: both}
+	for pos := range p.with {
+		p.with[pos] = make([]valSet, size+1)
+	}
+	for a := 1; a <= size; a++ {
+		for b := 1; b <= size; b++ {
+			if a != b && rel(a, b) {
+				p.with[0][b] |= valBit(CelVal(a))
+				p.with[1][a] |= valBit(CelVal(b))
+				p.open[0] |= valBit(CelVal(a))
+				p.open[1] |= valBit(CelVal(b))
+			}
+		}
+	}
+	return p
+}
+
+func (p *pairRule) unit() Unit  { return p.id }
+func (p *pairRule) cels() []int { return p.both }
+
+// Values for the cel at position pos that meet the relation with some
+// value of the other cel, or with its value if placed
+func (p *pairRule) fits(gp *grid, pos int) valSet {
+	if other := gp.value[p.both[1-pos]]; other != Blank {
+		return p.with[pos][other]
+	}
+	return p.open[pos]
+}
+
+func (p *pairRule) options(gp *grid, opts []valSet) {
+	opts[0], opts[1] = p.fits(gp, 0), p.fits(gp, 1)
+}
+
+func (p *pairRule) holds(gp *grid) bool {
+	for pos, idx := range p.both {
+		if val := gp.value[idx]; val != Blank && !p.fits(gp, pos).has(val) {
+			return false
+		}
+	}
+	return true
+}
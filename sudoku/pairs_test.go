@@ -0,0 +1,312 @@
+// "go test" program for marks between neighbouring cels
+
+package sudoku
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// True if every mark of a full grid holds, and in negative mode every
+// unmarked pair of neighbours meets no relation of the family
+func marksHold(sg *SizedGrid) bool {
+	val := func(cel Cel) int {
+		return int(sg.Cels[cel.Row][cel.Col])
+	}
+	key := func(p Pair) string {
+		if p.A.Row > p.B.Row || p.A.Col > p.B.Col {
+			p.A, p.B = p.B, p.A
+		}
+		return fmt.Sprint(p)
+	}
+	kropki, xv := make(map[string]bool), make(map[string]bool)
+	for _, p := range sg.White {
+		if d := val(p.A) - val(p.B); d != 1 && d != -1 {
+			return false
+		}
+		kropki[key(p)] = true
+	}
+	for _, p := range sg.Black {
+		if val(p.A) != 2*val(p.B) && val(p.B) != 2*val(p.A) {
+			return false
+		}
+		kropki[key(p)] = true
+	}
+	for _, p := range sg.X {
+		if val(p.A)+val(p.B) != 10 {
+			return false
+		}
+		xv[key(p)] = true
+	}
+	for _, p := range sg.V {
+		if val(p.A)+val(p.B) != 5 {
+			return false
+		}
+		xv[key(p)] = true
+	}
+	for _, p := range sg.Greater {
+		if val(p.A) <= val(p.B) {
+			return false
+		}
+	}
+
+	for _, p := range allNeighbours(sg.Size()) {
+		a, b := val(p.A), val(p.B)
+		if sg.NegativeKropki && !kropki[key(p)] && (a-b == 1 || b-a == 1 || a == 2*b || b == 2*a) {
+			return false
+		}
+		if sg.NegativeXV && !xv[key(p)] && (a+b == 10 || a+b == 5) {
+			return false
+		}
+	}
+	return true
+}
+
+// Every pair of cels side by side or one above the other
+func allNeighbours(size int) []Pair {
+	var pairs []Pair
+	for row := 0; row < size; row++ {
+		for col := 0; col < size; col++ {
+			if col+1 < size {
+				pairs = append(pairs, Pair{Cel{row, col}, Cel{row, col + 1}})
+			}
+			if row+1 < size {
+				pairs = append(pairs, Pair{Cel{row, col}, Cel{row + 1, col}})
+			}
+		}
+	}
+	return pairs
+}
+
+// Every mark that holds in a solved grid, with both negative modes on.
+// The greater-than signs go between the pairs of the first row
+func marksFrom(soln *SizedGrid) Constraints {
+	cs := Constraints{NegativeKropki: true, NegativeXV: true}
+	for _, p := range allNeighbours(soln.Size()) {
+		a, b := soln.Cels[p.A.Row][p.A.Col], soln.Cels[p.B.Row][p.B.Col]
+		switch {
+		case a-b == 1 || b-a == 1:
+			cs.White = append(cs.White, p)
+		case a == 2*b || b == 2*a:
+			cs.Black = append(cs.Black, p)
+		}
+		switch a + b {
+		case 10:
+			cs.X = append(cs.X, p)
+		case 5:
+			cs.V = append(cs.V, p)
+		}
+		if p.A.Row == 0 && p.B.Row == 0 {
+			if a < b {
+				p.A, p.B = p.B, p.A
+			}
+			cs.Greater = append(cs.Greater, p)
+		}
+	}
+	return cs
+}
+
+func TestMarkOptions(t *testing.T) {
+
+	var gp grid
+	expect := func(what string, cel Cel, vals ...CelVal) {
+		var want valSet
+		for _, val := range vals {
+			want |= valBit(val)
+		}
+		if opts := gp.options(cel.Row*GridSize + cel.Col); opts != want {
+			t.Error(fmt.Sprintf("%s: cel %v has options %v.  Expected %v", what, cel, opts, want))
+		}
+	}
+	pair := Pair{Cel{0, 0}, Cel{0, 1}}
+
+	cases := []struct {
+		name       string
+		cs         Constraints
+		blank      []CelVal // Options of B with both cels blank
+		after5     []CelVal // Options of B with 5 in A
+		blankFirst []CelVal // Options of A with both cels blank
+	}{
+		{"white", Constraints{White: []Pair{pair}}, []CelVal{1, 2, 3, 4, 5, 6, 7, 8, 9}, []CelVal{4, 6}, nil},
+		{"black", Constraints{Black: []Pair{pair}}, []CelVal{1, 2, 3, 4, 6, 8}, nil, nil},
+		{"x", Constraints{X: []Pair{pair}}, []CelVal{1, 2, 3, 4, 6, 7, 8, 9}, nil, nil},
+		{"v", Constraints{V: []Pair{pair}}, []CelVal{1, 2, 3, 4}, nil, nil},
+		{"greater", Constraints{Greater: []Pair{pair}}, []CelVal{1, 2, 3, 4, 5, 6, 7, 8}, []CelVal{1, 2, 3, 4}, []CelVal{2, 3, 4, 5, 6, 7, 8, 9}},
+		{"negative kropki", Constraints{NegativeKropki: true}, []CelVal{1, 2, 3, 4, 5, 6, 7, 8, 9}, []CelVal{1, 2, 3, 7, 8, 9}, nil},
+		{"negative xv", Constraints{NegativeXV: true}, []CelVal{1, 2, 3, 4, 5, 6, 7, 8, 9}, []CelVal{1, 2, 3, 4, 6, 7, 8, 9}, nil},
+	}
+	for _, c := range cases {
+		if err := (rules{constraints: c.cs}).check(GridSize); err != nil {
+			t.Fatal(fmt.Sprintf("%s: marks not built.  Returned: %v", c.name, err))
+		}
+		gp.init(newGeometry(classic.shape, rules{constraints: c.cs}))
+		if c.blankFirst != nil {
+			expect(c.name, pair.A, c.blankFirst...)
+		}
+		expect(c.name, pair.B, c.blank...)
+		if c.after5 != nil {
+			gp.place(0, 5)
+			expect(c.name+" after 5", pair.B, c.after5...)
+		}
+	}
+}
+
+func TestMarks(t *testing.T) {
+
+	var puzzle, soln Grid = hardGrid, hardGrid
+	Solve(&soln)
+	sizedSoln := SizedGridOf(&soln)
+	cs := marksFrom(&sizedSoln)
+
+	// The full set of marks pins down the blank grid alone
+	for _, solver := range []Solver{Backtrack, DancingLinks} {
+		sg := NewSizedGrid(classic.shape)
+		sg.Constraints = cs
+		if n, err := CountSolutionsSizedWith(context.Background(), solver, &sg, 2); err != nil || n != 1 {
+			t.Error(fmt.Sprintf("%T: marks not unique.  Returned: %d, %v", solver, n, err))
+		}
+		if err := SolveSizedWith(context.Background(), solver, &sg); err != nil || !solvedSized(&sg) || !marksHold(&sg) {
+			t.Error(fmt.Sprintf("%T: marks not solved.  Returned: %v", solver, err))
+		}
+	}
+
+	// Logic only uses eliminations the marks allow
+	sg := SizedGridOf(&puzzle)
+	sg.Constraints = cs
+	checkSizedLogic(t, "marks", &sg, &sizedSoln)
+
+	// The candidates service prunes with the marks
+	var jCands JsonCandidates
+	jCands.V = []Pair{{Cel{0, 0}, Cel{0, 1}}}
+	Jcandidates(&jCands)
+	if jCands.Code != "" || fmt.Sprint(jCands.Candidates[0][0]) != "[1 2 3 4]" {
+		t.Error(fmt.Sprintf("V not in the candidates.  Returned: %v, %s", jCands.Candidates[0][0], jCands.Status))
+	}
+
+	// Givens that break a mark, and givens that break negative mode
+	sg = NewSizedGrid(classic.shape)
+	sg.White = []Pair{{Cel{0, 0}, Cel{1, 0}}}
+	sg.Cels[0][0], sg.Cels[1][0] = 1, 5
+	var celErr *CelError
+	if err := SolveSized(&sg); !errors.As(err, &celErr) || celErr.Err != ErrConstraint || celErr.Unit != (Unit{"white", 0}) {
+		t.Error(fmt.Sprintf("White dot broken.  Expected ErrConstraint.  Returned: %v", err))
+	}
+	sg.White, sg.NegativeKropki = nil, true
+	sg.Cels[1][0] = 2
+	if err := SolveSized(&sg); !errors.As(err, &celErr) || celErr.Err != ErrConstraint || celErr.Unit.Kind != "kropki" {
+		t.Error(fmt.Sprintf("Missing dot broken.  Expected ErrConstraint.  Returned: %v", err))
+	}
+}
+
+func TestBadMarks(t *testing.T) {
+
+	bad := map[string]Constraints{
+		"off grid":       {White: []Pair{{Cel{0, 8}, Cel{0, 9}}}},
+		"not neighbours": {Black: []Pair{{Cel{0, 0}, Cel{1, 1}}}},
+		"same cel":       {Greater: []Pair{{Cel{0, 0}, Cel{0, 0}}}},
+	}
+	for name, cs := range bad {
+		sg := NewSizedGrid(classic.shape)
+		sg.Constraints = cs
+		if err := SolveSized(&sg); !errors.Is(err, ErrBadConstraints) {
+			t.Error(fmt.Sprintf("%s: expected ErrBadConstraints.  Returned: %v", name, err))
+		}
+	}
+
+	// No two values up to 4 add up to 10
+	sg := NewSizedGrid(Shape{2, 2})
+	sg.X = []Pair{{Cel{0, 0}, Cel{0, 1}}}
+	if err := SolveSized(&sg); !errors.Is(err, ErrBadConstraints) {
+		t.Error(fmt.Sprintf("4x4 X: expected ErrBadConstraints.  Returned: %v", err))
+	}
+}
+
+func TestJsolveMarks(t *testing.T) {
+
+	var soln Grid = hardGrid
+	Solve(&soln)
+	sizedSoln := SizedGridOf(&soln)
+	cs := marksFrom(&sizedSoln)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"white":          cs.White,
+		"black":          cs.Black,
+		"x":              cs.X,
+		"v":              cs.V,
+		"negativeKropki": true,
+		"negativeXV":     true,
+		"solver":         "dlx",
+	})
+	var jGrid JsonGrid
+	if err := json.Unmarshal(body, &jGrid); err != nil || len(jGrid.White) != len(cs.White) || !jGrid.NegativeXV {
+		t.Fatal(fmt.Sprintf("Marks not decoded.  Returned: %v", err))
+	}
+
+	Jsolve(&jGrid)
+	if jGrid.Code != "" || jGrid.Solution != soln {
+		t.Error(fmt.Sprintf("Jsolve with marks failed.  Returned: %s", jGrid.Status))
+	}
+
+	jGrid.V = append(jGrid.V, Pair{Cel{0, 0}, Cel{0, 2}})
+	Jsolve(&jGrid)
+	if jGrid.Code != "bad_constraints" {
+		t.Error(fmt.Sprintf("Failed to catch a bad mark.  Returned: %s", jGrid.Status))
+	}
+}
+
+// True if no neighbouring cels of a full grid hold consecutive values
+func nonConsecutiveHolds(sg *SizedGrid) bool {
+	for _, p := range allNeighbours(sg.Size()) {
+		if d := int(sg.Cels[p.A.Row][p.A.Col]) - int(sg.Cels[p.B.Row][p.B.Col]); d == 1 || d == -1 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestNonConsecutive(t *testing.T) {
+
+	var gp grid
+	gp.init(newGeometry(classic.shape, rules{variants: Variants{NonConsecutive: true}}))
+	gp.place(0, 5)
+	want := valBit(1) | valBit(2) | valBit(3) | valBit(7) | valBit(8) | valBit(9)
+	if opts := gp.options(1); opts != want {
+		t.Error(fmt.Sprintf("Cel beside a 5 has options %v.  Expected %v", opts, want))
+	}
+	if opts := gp.options(GridSize); opts != want {
+		t.Error(fmt.Sprintf("Cel below a 5 has options %v.  Expected %v", opts, want))
+	}
+
+	for _, shape := range []Shape{{2, 3}, {3, 3}} {
+		for _, solver := range []Solver{Backtrack, DancingLinks} {
+			sg := NewSizedGrid(shape)
+			sg.NonConsecutive = true
+			if err := SolveSizedWith(context.Background(), solver, &sg); err != nil || !solvedSized(&sg) || !nonConsecutiveHolds(&sg) {
+				t.Error(fmt.Sprintf("%dx%d boxes, %T: blank grid not solved.  Returned: %v",
+					shape.BoxRows, shape.BoxCols, solver, err))
+			}
+		}
+	}
+
+	// Consecutive givens one above the other
+	sg := NewSizedGrid(classic.shape)
+	sg.NonConsecutive = true
+	sg.Cels[3][3], sg.Cels[4][3] = 7, 8
+	var celErr *CelError
+	if err := SolveSized(&sg); !errors.As(err, &celErr) || celErr.Err != ErrConstraint || celErr.Unit.Kind != "nonconsecutive" {
+		t.Error(fmt.Sprintf("Consecutive givens.  Expected ErrConstraint.  Returned: %v", err))
+	}
+
+	var jGrid JsonGrid
+	if err := json.Unmarshal([]byte(`{"nonConsecutive": true}`), &jGrid); err != nil || !jGrid.NonConsecutive {
+		t.Fatal(fmt.Sprintf("Variant not decoded.  Returned: %v", err))
+	}
+	Jsolve(&jGrid)
+	soln := SizedGridOf(&jGrid.Solution)
+	if jGrid.Code != "" || !nonConsecutiveHolds(&soln) {
+		t.Error(fmt.Sprintf("Jsolve ignored the variant.  Returned: %s", jGrid.Status))
+	}
+}
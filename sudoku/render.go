@@ -0,0 +1,136 @@
+//
+// Copyright 2020, 2021 Kenneth J. Gibson
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//
+// Text rendering of a puzzle.
+// Render draws a grid as rows of values with lines between the boxes,
+// then lists its line and shape constraints, one per line.  Constraints
+// are named and numbered from 0 as in the errors that report them, and
+// cels are named the way players do, from 1 (r3c4 is row 3, column 4).
+//
+
+package sudoku
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//  The grid and its constraints as text, for printing or pasting into
+//  a puzzle collection.  Blank cels are shown as '.', as are any missing
+//  from Cels if it has fewer rows or columns than the size.  A jigsaw
+//  grid is drawn without box lines, as its regions are not boxes.
+
+func (sg *SizedGrid) Render() string {
+
+	var b strings.Builder
+	size := sg.Size()
+	width := len(strconv.Itoa(size))
+
+	boxRows, boxCols := sg.BoxRows, sg.BoxCols
+	if sg.Regions != nil || boxRows < 1 || boxCols < 1 {
+		boxRows, boxCols = size, size
+	}
+
+	line := "+"
+	for col := 0; col < size; col += boxCols {
+		line += strings.Repeat("-", boxCols*(width+1)+1) + "+"
+	}
+	for row := 0; row < size; row++ {
+		if row%boxRows == 0 {
+			b.WriteString(line + "\n")
+		}
+		for col := 0; col < size; col++ {
+			if col%boxCols == 0 {
+				b.WriteString("| ")
+			}
+			val := "."
+			if row < len(sg.Cels) && col < len(sg.Cels[row]) && sg.Cels[row][col] != Blank {
+				val = strconv.Itoa(int(sg.Cels[row][col]))
+			}
+			fmt.Fprintf(&b, "%*s ", width, val)
+		}
+		b.WriteString("|\n")
+	}
+	b.WriteString(line + "\n")
+
+	sg.Constraints.render(&b)
+	return b.String()
+}
+
+//  Write one line for each constraint, in the order the engine numbers
+//  them
+
+func (cs *Constraints) render(b *strings.Builder) {
+
+	for i, cels := range cs.Thermos {
+		fmt.Fprintf(b, "%v: %s\n", Unit{"thermo", i}, celList(cels, " "))
+	}
+	for i, arrow := range cs.Arrows {
+		fmt.Fprintf(b, "%v: %s = %s\n", Unit{"arrow", i}, celText(arrow.Circle), celList(arrow.Line, " + "))
+	}
+	for i, sw := range cs.Sandwiches {
+		line := fmt.Sprintf("r%d", sw.Line.Index+1)
+		if sw.Line.Kind == "column" {
+			line = fmt.Sprintf("c%d", sw.Line.Index+1)
+		}
+		fmt.Fprintf(b, "%v: %s sum %d\n", Unit{"sandwich", i}, line, sw.Sum)
+	}
+	if cs.Odd != nil {
+		fmt.Fprintf(b, "odd: %s\n", celList(cs.Odd, " "))
+	}
+	if cs.Even != nil {
+		fmt.Fprintf(b, "even: %s\n", celList(cs.Even, " "))
+	}
+
+	marks := []struct {
+		kind  string
+		pairs []Pair
+		sep   string
+	}{
+		{"white", cs.White, " "},
+		{"black", cs.Black, " "},
+		{"x", cs.X, " "},
+		{"v", cs.V, " "},
+		{"greater", cs.Greater, " > "},
+	}
+	for _, m := range marks {
+		for i, p := range m.pairs {
+			fmt.Fprintf(b, "%v: %s%s%s\n", Unit{m.kind, i}, celText(p.A), m.sep, celText(p.B))
+		}
+	}
+	if cs.NegativeKropki {
+		b.WriteString("negative kropki\n")
+	}
+	if cs.NegativeXV {
+		b.WriteString("negative xv\n")
+	}
+}
+
+// A cel named the way players do, from 1
+func celText(cel Cel) string {
+	return fmt.Sprintf("r%dc%d", cel.Row+1, cel.Col+1)
+}
+
+// Cels named the way players do, joined by sep
+func celList(cels []Cel, sep string) string {
+	names := make([]string, len(cels))
+	for i, cel := range cels {
+		names[i] = celText(cel)
+	}
+	return strings.Join(names, sep)
+}
@@ -0,0 +1,67 @@
+// "go test" program for the text rendering of a puzzle
+
+package sudoku
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+
+	sg := NewSizedGrid(Shape{2, 2})
+	sg.Cels[0][0], sg.Cels[3][3] = 1, 4
+	sg.Thermos = [][]Cel{{{1, 0}, {1, 1}}}
+	sg.Arrows = []Arrow{{Cel{2, 2}, []Cel{{2, 3}, {3, 2}}}}
+	sg.Sandwiches = []Sandwich{{Unit{"column", 1}, 5}, {Unit{"row", 3}, 0}}
+	sg.Odd, sg.Even = []Cel{{0, 0}, {2, 0}}, []Cel{{3, 3}}
+	sg.White = []Pair{{Cel{0, 0}, Cel{1, 0}}}
+	sg.Greater = []Pair{{Cel{0, 1}, Cel{0, 2}}}
+	sg.NegativeKropki = true
+
+	want := `+-----+-----+
+| 1 . | . . |
+| . . | . . |
++-----+-----+
+| . . | . . |
+| . . | . 4 |
++-----+-----+
+thermo 0: r2c1 r2c2
+arrow 0: r3c3 = r3c4 + r4c3
+sandwich 0: c2 sum 5
+sandwich 1: r4 sum 0
+odd: r1c1 r3c1
+even: r4c4
+white 0: r1c1 r2c1
+greater 0: r1c2 > r1c3
+negative kropki
+`
+	if got := sg.Render(); got != want {
+		t.Error(fmt.Sprintf("Wrong rendering.  Returned:\n%s", got))
+	}
+
+	// Cels missing from a short or nil Cels are drawn blank
+	sg.Cels = []CelVals{{1}, nil, {0, 0, 0, 0, 0}}
+	if got := strings.Split(sg.Render(), "\n"); len(got) != len(strings.Split(want, "\n")) ||
+		got[1] != "| 1 . | . . |" || got[5] != "| . . | . . |" {
+		t.Error(fmt.Sprintf("Short Cels drawn wrongly.  Returned:\n%s", strings.Join(got, "\n")))
+	}
+	sg.Cels = nil
+	if got := strings.Split(sg.Render(), "\n"); got[1] != "| . . | . . |" {
+		t.Error(fmt.Sprintf("Nil Cels drawn wrongly.  Returned:\n%s", strings.Join(got, "\n")))
+	}
+
+	// Two-digit values line up, and a jigsaw grid has no box lines
+	sg = NewSizedGrid(Shape{3, 4})
+	sg.Cels[0][0], sg.Cels[0][1] = 12, 3
+	if got := strings.Split(sg.Render(), "\n")[1]; got != "| 12  3  .  . |  .  .  .  . |  .  .  .  . |" {
+		t.Error(fmt.Sprintf("Wrong row.  Returned: %s", got))
+	}
+	var config Grid = hardGrid
+	sg = SizedGridOf(&config)
+	sg.Regions = make(RegionMap, GridSize)
+	if got := strings.Split(sg.Render(), "\n"); len(got) != GridSize+3 || strings.Count(got[0], "+") != 2 {
+		t.Error(fmt.Sprintf("Jigsaw drawn with boxes.  Returned:\n%s", strings.Join(got, "\n")))
+	}
+}
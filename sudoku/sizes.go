@@ -0,0 +1,277 @@
+//
+// Copyright 2020, 2021 Kenneth J. Gibson
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//
+// Grids of other sizes.
+// A grid of side n is split into n boxes of r rows by c columns, where
+// r*c = n, and holds the values 1 to n.  Boxes may be rectangular, such
+// as the 2x3 boxes of a 6x6 grid or the 3x4 boxes of a 12x12 grid.
+// Grid stays the fixed 9x9 array, and SizedGrid holds a grid of any
+// supported size.  The engine itself works on any size.
+//
+
+package sudoku
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Largest supported grid side
+const MaxSize = 25
+
+// Shape of a grid, given by the rows and columns of each box.  The grid
+// has BoxRows*BoxCols cels on a side.
+type Shape struct {
+	BoxRows int `json:"boxRows"`
+	BoxCols int `json:"boxCols"`
+}
+
+// Cels on a side of the grid, and the largest value
+func (s Shape) Size() int {
+	return s.BoxRows * s.BoxCols
+}
+
+//  Shape for a grid of the given side, with boxes as close to square as
+//  possible and no taller than they are wide: 2x2 for 4, 2x3 for 6,
+//  3x4 for 12 and so on.  Returns ErrBadSize if no such shape exists,
+//  such as for a prime side.
+
+func ShapeForSize(size int) (Shape, error) {
+
+	// Keep the largest divisor up to the square root
+	shape := Shape{}
+	for rows := 2; rows*rows <= size; rows++ {
+		if size%rows == 0 {
+			shape = Shape{rows, size / rows}
+		}
+	}
+	if shape.BoxRows == 0 || size > MaxSize {
+		return shape, fmt.Errorf("%w %d", ErrBadSize, size)
+	}
+	return shape, nil
+}
+
+// Rules a grid is solved under, beyond its shape
+type rules struct {
+	variants Variants
+	regions  RegionMap // Jigsaw regions replacing the boxes, or nil
+	extra    [][]Cel   // Extra regions of the puzzle's own, or nil
+	cages    []Cage    // Killer cages, or nil
+
+	constraints Constraints
+}
+
+// True if the rules include anything that belongs to one puzzle
+func (r rules) ownUnits() bool {
+	return r.regions != nil || r.extra != nil || r.cages != nil || !r.constraints.empty()
+}
+
+//  Check the puzzle's own units against a grid of the given side.
+//  Returns ErrBadRegions, ErrBadCages or ErrBadConstraints for the first
+//  problem found.
+
+func (r rules) check(size int) error {
+
+	if r.regions != nil {
+		if err := r.regions.check(size); err != nil {
+			return err
+		}
+	}
+	if err := checkExtra(r.extra, size); err != nil {
+		return err
+	}
+	if r.cages != nil {
+		if err := checkCages(r.cages, size); err != nil {
+			return err
+		}
+	}
+	_, err := r.constraints.build(size)
+	return err
+}
+
+// Shape and variants, which together fix the units of a grid without
+// units of its own
+type layout struct {
+	shape    Shape
+	variants Variants
+}
+
+// Geometries already built, by layout
+var geometries = struct {
+	sync.Mutex
+	byLayout map[layout]*geometry
+}{byLayout: map[layout]*geometry{{classic.shape, classic.variants}: classic}}
+
+//  Geometry for a shape and rules, building it the first time it is
+//  asked for.  Jigsaw regions, extra regions, killer cages and
+//  constraints belong to one puzzle, so a grid with any of them gets a
+//  geometry of its own.  Returns ErrBadSize for boxes under 2 cels on a
+//  side or a grid over MaxSize, and the error from rules.check for bad
+//  rules of the puzzle's own.
+
+func geometryOf(shape Shape, r rules) (*geometry, error) {
+
+	if shape.BoxRows < 2 || shape.BoxCols < 2 || shape.Size() > MaxSize {
+		return nil, fmt.Errorf("%w %dx%d", ErrBadSize, shape.BoxRows, shape.BoxCols)
+	}
+	if r.ownUnits() {
+		if err := r.check(shape.Size()); err != nil {
+			return nil, err
+		}
+		return newGeometry(shape, r), nil
+	}
+
+	geometries.Lock()
+	defer geometries.Unlock()
+
+	key := layout{shape, r.variants}
+	geo, ok := geometries.byLayout[key]
+	if !ok {
+		geo = newGeometry(shape, r)
+		geometries.byLayout[key] = geo
+	}
+	return geo, nil
+}
+
+// A grid of any supported size, optionally with variant rules, jigsaw
+// regions, extra regions, killer cages or constraints.  Cels holds
+// Size() rows of Size() values each, with 0 representing a blank cel.
+type SizedGrid struct {
+	Shape
+	Variants
+	Constraints
+	Regions RegionMap // Replaces the boxes if not nil
+	Extra   [][]Cel   // Extra regions, which may overlap anything
+	Cages   []Cage    // Killer cages, if any
+	Cels    []CelVals
+}
+
+// A blank grid of the given shape
+func NewSizedGrid(shape Shape) SizedGrid {
+	sg := SizedGrid{Shape: shape, Cels: make([]CelVals, shape.Size())}
+	for row := range sg.Cels {
+		sg.Cels[row] = make(CelVals, shape.Size())
+	}
+	return sg
+}
+
+// Convert a standard grid.  Set Variants on the result to add variant
+// rules
+func SizedGridOf(configP *Grid) SizedGrid {
+	sg := NewSizedGrid(classic.shape)
+	for row := range sg.Cels {
+		copy(sg.Cels[row], configP[row][:])
+	}
+	return sg
+}
+
+func (sg *SizedGrid) geo() (*geometry, error) {
+
+	geo, err := geometryOf(sg.Shape, rules{sg.Variants, sg.Regions, sg.Extra, sg.Cages, sg.Constraints})
+	if err != nil {
+		return nil, err
+	}
+	if len(sg.Cels) != geo.size {
+		return nil, fmt.Errorf("%w: %d rows for size %d", ErrBadSize, len(sg.Cels), geo.size)
+	}
+	for row, cels := range sg.Cels {
+		if len(cels) != geo.size {
+			return nil, fmt.Errorf("%w: %d cels in row %d for size %d", ErrBadSize, len(cels), row, geo.size)
+		}
+	}
+	return geo, nil
+}
+
+// A blank grid with the same shape and rules
+func (sg *SizedGrid) blank() SizedGrid {
+	soln := NewSizedGrid(sg.Shape)
+	soln.Variants, soln.Constraints = sg.Variants, sg.Constraints
+	soln.Regions, soln.Extra, soln.Cages = sg.Regions, sg.Extra, sg.Cages
+	return soln
+}
+
+func (sg *SizedGrid) cel(row, col int) CelVal {
+	return sg.Cels[row][col]
+}
+
+func (sg *SizedGrid) setCel(row, col int, val CelVal) {
+	sg.Cels[row][col] = val
+}
+
+//  Public entry point for solving a grid of any size or with variant rules.
+//  Same as Solve, and also returns ErrBadSize for an unsupported shape or
+//  a Cels array that does not match it.
+
+func SolveSized(configP *SizedGrid) error {
+
+	return SolveSizedWith(context.Background(), DefaultSolver, configP)
+}
+
+//  Same as SolveSized, using the given solver backend and giving up when
+//  the context is canceled or its deadline passes.
+
+func SolveSizedWith(ctx context.Context, solver Solver, configP *SizedGrid) error {
+
+	var soln SizedGrid
+
+	// Stop at the first solution
+	count, err := solver.SizedSolutions(ctx, configP, 1, func(s SizedGrid) bool {
+		soln = s
+		return false
+	})
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrUnsolvable
+	}
+
+	*configP = soln
+	return nil
+}
+
+//  Same as CountSolutionsWith for a grid of any size
+
+func CountSolutionsSizedWith(ctx context.Context, solver Solver, configP *SizedGrid, limit int) (int, error) {
+
+	return solver.SizedSolutions(ctx, configP, limit, func(SizedGrid) bool {
+		return true
+	})
+}
+
+//  The shape a JsonGrid asks for.  A Size of 0 or 9 is the standard grid
+//  in Solution.  Any other Size uses Cels, with boxes of BoxRows by
+//  BoxCols if given, or the shape from ShapeForSize.
+
+func (jGridP *JsonGrid) shape() (Shape, bool, error) {
+
+	if jGridP.Size == 0 || jGridP.Size == GridSize {
+		return classic.shape, false, nil
+	}
+	if jGridP.BoxRows != 0 || jGridP.BoxCols != 0 {
+		shape := Shape{jGridP.BoxRows, jGridP.BoxCols}
+		if shape.Size() != jGridP.Size {
+			return shape, true, fmt.Errorf("%w: %dx%d boxes for size %d", ErrBadSize,
+				shape.BoxRows, shape.BoxCols, jGridP.Size)
+		}
+		return shape, true, nil
+	}
+	shape, err := ShapeForSize(jGridP.Size)
+	return shape, true, err
+}
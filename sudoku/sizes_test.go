@@ -0,0 +1,159 @@
+// "go test" program for grids of other sizes
+
+package sudoku
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// True if the grid is completely filled with no clashes
+func solvedSized(sg *SizedGrid) bool {
+	conflicts, err := ValidateSized(sg)
+	if err != nil || len(conflicts) != 0 {
+		return false
+	}
+	for _, cels := range sg.Cels {
+		for _, val := range cels {
+			if val == Blank {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestShapeForSize(t *testing.T) {
+
+	want := map[int]Shape{4: {2, 2}, 6: {2, 3}, 8: {2, 4}, 9: {3, 3}, 12: {3, 4}, 16: {4, 4}, 25: {5, 5}}
+	for size, shape := range want {
+		got, err := ShapeForSize(size)
+		if err != nil || got != shape {
+			t.Error(fmt.Sprintf("Size %d: shape %v, want %v.  Returned: %v", size, got, shape, err))
+		}
+	}
+
+	for _, size := range []int{0, 1, 2, 3, 5, 7, 13, 36} {
+		if _, err := ShapeForSize(size); !errors.Is(err, ErrBadSize) {
+			t.Error(fmt.Sprintf("Size %d: expected ErrBadSize.  Returned: %v", size, err))
+		}
+	}
+}
+
+func TestSolveSized(t *testing.T) {
+
+	for _, shape := range []Shape{{2, 2}, {2, 3}, {3, 2}, {3, 4}, {4, 4}, {5, 5}} {
+
+		// Fill a blank grid with Dancing Links, so the puzzle below is
+		// solved by backtracking from a grid it did not make
+		soln := NewSizedGrid(shape)
+		if err := SolveSizedWith(context.Background(), DancingLinks, &soln); err != nil || !solvedSized(&soln) {
+			t.Error(fmt.Sprintf("%dx%d boxes: blank grid not solved.  Returned: %v", shape.BoxRows, shape.BoxCols, err))
+			continue
+		}
+
+		// Clear every fourth cel and solve again with each backend
+		for _, solver := range []Solver{Backtrack, DancingLinks} {
+			name := fmt.Sprintf("%dx%d boxes, %T", shape.BoxRows, shape.BoxCols, solver)
+			puzzle := NewSizedGrid(shape)
+			for row := range puzzle.Cels {
+				for col := range puzzle.Cels[row] {
+					if (row+col)%4 != 0 {
+						puzzle.Cels[row][col] = soln.Cels[row][col]
+					}
+				}
+			}
+			config := puzzle
+			if err := SolveSizedWith(context.Background(), solver, &config); err != nil || !solvedSized(&config) {
+				t.Error(fmt.Sprintf("%s: puzzle not solved.  Returned: %v", name, err))
+				continue
+			}
+			for row := range puzzle.Cels {
+				for col, val := range puzzle.Cels[row] {
+					if val != Blank && config.Cels[row][col] != val {
+						t.Error(fmt.Sprintf("%s: solution changed clue at %d, %d", name, row, col))
+					}
+				}
+			}
+		}
+	}
+
+	// Every 4x4 grid, counted by both backends
+	blank := NewSizedGrid(Shape{2, 2})
+	for _, solver := range []Solver{Backtrack, DancingLinks} {
+		if count, err := CountSolutionsSizedWith(context.Background(), solver, &blank, 0); err != nil || count != 288 {
+			t.Error(fmt.Sprintf("%T: counted %d 4x4 grids, want 288.  Returned: %v", solver, count, err))
+		}
+	}
+
+	// The standard grid gives the same answer either way
+	var puzzle Grid = hardGrid
+	sized := SizedGridOf(&puzzle)
+	if Solve(&puzzle) != nil || SolveSized(&sized) != nil || fmt.Sprint(sized.Cels) != fmt.Sprint(SizedGridOf(&puzzle).Cels) {
+		t.Error("Sized and standard solutions differ")
+	}
+}
+
+func TestSizedErrors(t *testing.T) {
+
+	sg := NewSizedGrid(Shape{2, 3})
+	sg.Cels[0][0], sg.Cels[0][5] = 4, 4
+	var celErr *CelError
+	if err := SolveSized(&sg); !errors.Is(err, ErrConflict) || !errors.As(err, &celErr) || celErr.Unit.Kind != "row" {
+		t.Error(fmt.Sprintf("Expected ErrConflict in a row.  Returned: %v", err))
+	}
+
+	sg.Cels[0][5] = 7
+	if err := SolveSized(&sg); !errors.Is(err, ErrOutOfRange) {
+		t.Error(fmt.Sprintf("Expected ErrOutOfRange.  Returned: %v", err))
+	}
+
+	bad := []SizedGrid{
+		NewSizedGrid(Shape{1, 4}),
+		NewSizedGrid(Shape{6, 6}),
+		{Shape: Shape{2, 2}, Cels: NewSizedGrid(Shape{2, 3}).Cels},
+		{Shape: Shape{2, 2}, Cels: []CelVals{{0, 0, 0, 0}, {0, 0, 0}, {0, 0, 0, 0}, {0, 0, 0, 0}}},
+	}
+	for i := range bad {
+		if err := SolveSized(&bad[i]); !errors.Is(err, ErrBadSize) {
+			t.Error(fmt.Sprintf("Grid %d: expected ErrBadSize.  Returned: %v", i, err))
+		}
+	}
+}
+
+func TestJsolveSized(t *testing.T) {
+
+	jGrid := JsonGrid{Size: 6, Cels: NewSizedGrid(Shape{2, 3}).Cels}
+	Jsolve(&jGrid)
+	soln := SizedGrid{Shape: Shape{jGrid.BoxRows, jGrid.BoxCols}, Cels: jGrid.Cels}
+	if jGrid.Code != "" || jGrid.Unique || !solvedSized(&soln) {
+		t.Error(fmt.Sprintf("Jsolve failed on a 6x6 grid.  Returned: %s", jGrid.Status))
+	}
+
+	jGrid = JsonGrid{Size: 6, BoxRows: 3, BoxCols: 3, Cels: NewSizedGrid(Shape{2, 3}).Cels}
+	Jsolve(&jGrid)
+	if jGrid.Code != "bad_size" {
+		t.Error(fmt.Sprintf("Failed to catch mismatched boxes.  Returned: %s", jGrid.Status))
+	}
+
+	jGrid = JsonGrid{Size: 4, Cels: []CelVals{{1, 1, 0, 0}, {0, 0, 0, 0}, {0, 0, 0, 0}, {0, 0, 0, 1}}}
+	Jsolve(&jGrid)
+	if jGrid.Code != "conflict" || len(jGrid.Conflicts) != 2 {
+		t.Error(fmt.Sprintf("Failed to list 4x4 conflicts.  Returned: %s, %v", jGrid.Status, jGrid.Conflicts))
+	}
+
+	// Only the standard grid is sent in Solution
+	for _, jGrid := range []JsonGrid{{Size: 4, Cels: NewSizedGrid(Shape{2, 2}).Cels}, {}} {
+		var fields map[string]json.RawMessage
+		body, err := json.Marshal(jGrid)
+		if err == nil {
+			err = json.Unmarshal(body, &fields)
+		}
+		if _, found := fields["solution"]; err != nil || found != (jGrid.Size == 0) || fields["status"] == nil {
+			t.Error(fmt.Sprintf("Wrong fields for size %d.  Returned: %s, %v", jGrid.Size, body, err))
+		}
+	}
+}
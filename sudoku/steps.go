@@ -0,0 +1,286 @@
+//
+// Copyright 2020, 2021 Kenneth J. Gibson
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//
+// Step by step solve trace.
+// Records each deduction made by the logical solver, in order, with a
+// written explanation, so a client can replay the solve for a player.
+//
+// Explanations use the usual player notation: r3c4 is row 3, column 4,
+// and rows, columns and boxes are numbered from 1.  The Cel and Unit
+// fields of a Step are numbered from 0 like the rest of the API.
+//
+
+package sudoku
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// A value placed in a cel
+type Placement struct {
+	Row   int    `json:"row"`
+	Col   int    `json:"col"`
+	Value CelVal `json:"value"`
+}
+
+// Candidates removed from a cel
+type Elimination struct {
+	Row    int     `json:"row"`
+	Col    int     `json:"col"`
+	Values CelVals `json:"values"`
+}
+
+// One deduction in a solve trace
+type Step struct {
+	Technique   Technique     `json:"technique"`
+	Placed      []Placement   `json:"placed,omitempty"`     // Values placed by this step
+	Eliminated  []Elimination `json:"eliminated,omitempty"` // Candidates removed by this step
+	Because     []Cel         `json:"because,omitempty"`    // Cels that justify the deduction
+	Units       []Unit        `json:"units,omitempty"`      // Units the deduction works within
+	Explanation string        `json:"explanation"`
+}
+
+// Exported struct for marshaling a solve trace to/from JSON.
+// The puzzle is sent in Solution and replaced by the solved grid,
+// as for JsonGrid.
+type JsonSteps struct {
+	Solution Grid   `json:"solution"`
+	Steps    []Step `json:"steps"`
+	Status   string `json:"status"`
+	Code     string `json:"code,omitempty"` // Machine-readable error code.  See ErrorCode
+}
+
+//  Public entry point for a step by step solve.
+//  Populates the Grid with the solution like Solve, and returns the same
+//  errors.  Also returns every deduction made, in order.  If logic alone
+//  cannot solve the puzzle, the last step is a Backtracking step that
+//  places the remaining values.
+
+func SolveSteps(configP *Grid) ([]Step, error) {
+
+	return SolveStepsContext(context.Background(), configP)
+}
+
+//  Same as SolveSteps, but gives up when the context is canceled or its
+//  deadline passes.
+
+func SolveStepsContext(ctx context.Context, configP *Grid) ([]Step, error) {
+
+	var lg logicGrid
+	var steps []Step
+
+	if err := lg.prepareLogical(ctx, configP); err != nil {
+		return nil, err
+	}
+
+	err := lg.solveLogical(ctx, func(st *step) {
+		steps = append(steps, st.export(lg.geo))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	lg.store(configP)
+	return steps, nil
+}
+
+// JSON version of SolveSteps.  Copies status into the JsonSteps struct
+// the same way Jsolve does for a JsonGrid.
+
+func Jsteps(jStepsP *JsonSteps) {
+
+	JstepsContext(context.Background(), jStepsP)
+}
+
+// Same as Jsteps, but gives up when the context is canceled or
+// its deadline passes.
+
+func JstepsContext(ctx context.Context, jStepsP *JsonSteps) {
+
+	steps, err := SolveStepsContext(ctx, &jStepsP.Solution)
+	if err != nil {
+		jStepsP.Steps = nil
+		jStepsP.Status = fmt.Sprintf("%v", err)
+		jStepsP.Code = ErrorCode(err)
+		return
+	}
+	jStepsP.Steps = steps
+	jStepsP.Status = fmt.Sprintf("Success")
+	jStepsP.Code = ""
+}
+
+// Convert an internal step to its exported form
+
+func (st *step) export(geo *geometry) Step {
+
+	out := Step{Technique: st.tech, Explanation: st.explain(geo)}
+
+	for _, p := range st.placed {
+		cel := geo.celOf(p.idx)
+		out.Placed = append(out.Placed, Placement{cel.Row, cel.Col, p.val})
+	}
+
+	// Group eliminations by cel, keeping the order cels first appear in
+	for _, e := range st.elims {
+		row, col := e.idx/geo.size, e.idx%geo.size
+		n := len(out.Eliminated)
+		if n > 0 && out.Eliminated[n-1].Row == row && out.Eliminated[n-1].Col == col {
+			out.Eliminated[n-1].Values = append(out.Eliminated[n-1].Values, e.val)
+			continue
+		}
+		out.Eliminated = append(out.Eliminated, Elimination{row, col, CelVals{e.val}})
+	}
+
+	for _, idx := range st.cause {
+		out.Because = append(out.Because, geo.celOf(idx))
+	}
+	for _, unit := range st.units {
+		out.Units = append(out.Units, geo.units[unit])
+	}
+	return out
+}
+
+// Player notation for a cel, e.g. r3c4
+func celName(geo *geometry, idx int) string {
+	return fmt.Sprintf("r%dc%d", idx/geo.size+1, idx%geo.size+1)
+}
+
+// Player notation for a unit, e.g. row 3
+func unitName(geo *geometry, unit int) string {
+	u := geo.units[unit]
+	return fmt.Sprintf("%s %d", u.Kind, u.Index+1)
+}
+
+// Join a list of names as "a", "a and b" or "a, b and c"
+func joinNames(names []string) string {
+	if len(names) <= 1 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
+
+func celNames(geo *geometry, cels []int) string {
+	var names []string
+	for _, idx := range cels {
+		names = append(names, celName(geo, idx))
+	}
+	return joinNames(names)
+}
+
+func valNames(vals valSet) string {
+	var names []string
+	for ; vals != 0; vals &^= valBit(vals.first()) {
+		names = append(names, fmt.Sprintf("%d", vals.first()))
+	}
+	return joinNames(names)
+}
+
+func unitNames(geo *geometry, units []int) string {
+	var names []string
+	for _, unit := range units {
+		names = append(names, unitName(geo, unit))
+	}
+	return joinNames(names)
+}
+
+//  What the step does: the placements, or the eliminations grouped by value
+
+func (st *step) effect(geo *geometry) string {
+
+	if len(st.placed) > 0 {
+		var names []string
+		for _, p := range st.placed {
+			names = append(names, fmt.Sprintf("%s is %d", celName(geo, p.idx), p.val))
+		}
+		return joinNames(names)
+	}
+
+	var cels []int
+	var vals valSet
+	for _, e := range st.elims {
+		if !containsCel(cels, e.idx) {
+			cels = append(cels, e.idx)
+		}
+		vals |= valBit(e.val)
+	}
+
+	// Hidden subsets remove every other value from their own cels
+	switch st.tech {
+	case HiddenPair, HiddenTriple, HiddenQuad:
+		return fmt.Sprintf("every other candidate can be removed from %s", celNames(geo, cels))
+	}
+
+	var parts []string
+	for ; vals != 0; vals &^= valBit(vals.first()) {
+		val := vals.first()
+		cels = cels[:0]
+		for _, e := range st.elims {
+			if e.val == val {
+				cels = append(cels, e.idx)
+			}
+		}
+		parts = append(parts, fmt.Sprintf("%d can be removed from %s", val, celNames(geo, cels)))
+	}
+	return strings.Join(parts, "; ")
+}
+
+//  Written explanation of the step for a player
+
+func (st *step) explain(geo *geometry) string {
+
+	var why string
+	half := len(st.units) / 2
+
+	switch st.tech {
+	case NakedSingle:
+		why = fmt.Sprintf("%s has only one candidate left", celName(geo, st.placed[0].idx))
+	case HiddenSingle:
+		why = fmt.Sprintf("%d has only one place left in %s", st.placed[0].val, unitName(geo, st.units[0]))
+	case NakedPair, NakedTriple, NakedQuad:
+		why = fmt.Sprintf("%s can only hold %s between them, so those values must go there within %s",
+			celNames(geo, st.cause), valNames(st.digits), unitName(geo, st.units[0]))
+	case HiddenPair, HiddenTriple, HiddenQuad:
+		why = fmt.Sprintf("%s can only go in %s within %s, so those cels must hold them",
+			valNames(st.digits), celNames(geo, st.cause), unitName(geo, st.units[0]))
+	case PointingPair, BoxLineReduction:
+		why = fmt.Sprintf("within %s, %s can only go in %s, so it must go there within %s",
+			unitName(geo, st.units[0]), valNames(st.digits), celNames(geo, st.cause), unitName(geo, st.units[1]))
+	case XWing, Swordfish, Jellyfish:
+		why = fmt.Sprintf("in %s, %s can only go in %s, so it must go in those cels within %s",
+			unitNames(geo, st.units[:half]), valNames(st.digits), celNames(geo, st.cause), unitNames(geo, st.units[half:]))
+	case XYWing:
+		why = fmt.Sprintf("whichever value pivot %s takes, one of %s and %s must be the value they share",
+			celName(geo, st.cause[0]), celName(geo, st.cause[1]), celName(geo, st.cause[2]))
+	case XYZWing:
+		why = fmt.Sprintf("one of %s must be the value all three share",
+			celNames(geo, st.cause))
+	case SimpleColoring:
+		why = fmt.Sprintf("%s alternates along the chain of pairs %s, so one of the two colors holds it",
+			valNames(st.digits), celNames(geo, st.cause))
+	case UniqueRectangle:
+		why = fmt.Sprintf("if %s only had %s, they could be swapped and the puzzle would not be unique",
+			celNames(geo, st.cause), valNames(st.digits))
+	case Backtracking:
+		if len(st.placed) == 1 {
+			return fmt.Sprintf("No logical technique applies.  Search shows %s.", st.effect(geo))
+		}
+		return "No logical technique applies, so the remaining cels were found by search"
+	}
+	return fmt.Sprintf("%v: %s.  So %s.", st.tech, why, st.effect(geo))
+}
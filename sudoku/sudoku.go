@@ -15,22 +15,29 @@
 //
 
 //
-// Sudoku solver engine package.  This package is fully contained in this file.
+// Sudoku solver engine package.  The core engine is contained in this file,
+// with additional solver backends and features in the other package files.
 // Implements an engine for solving sudoku puzzles.
 // Exports the types used to describe a sudoku puzzle, check for a legal config,
 // and for solving.  The solver returns a solved puzzle, or error if the puzzle is
 // either invalid or unsolvable.
 //
 // Terminology used:
-//	Grid:	The full 9x9 sudoku board
-//	Box:	The 3x3 subsections of the grid
+//	Grid:	The full sudoku board, 9x9 unless sized otherwise (see sizes.go)
+//	Box:	The subsections of the grid, 3x3 on a 9x9 grid
 //	Cel:	The individual cels that each hold one number
+//	Unit:	A row, column or box, or a region added by a variant (see
+//		variants.go).  Each unit must hold one of each number
 //
 
 package sudoku
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math/bits"
 )
 
 // Declare the exported types for describing a Soduku grid
@@ -44,250 +51,499 @@ const Blank CelVal = 0
 // The array describing the sudoku grid for passing to/from the engine
 type Grid [GridSize][GridSize]CelVal
 
+// A list of cel values.  Encodes to JSON as an array of numbers, where
+// a plain []CelVal would be encoded as a base64 string like a []byte
+type CelVals []CelVal
+
+func (vals CelVals) MarshalJSON() ([]byte, error) {
+	nums := make([]int, len(vals))
+	for i, val := range vals {
+		nums[i] = int(val)
+	}
+	return json.Marshal(nums)
+}
+
 // Exported struct for marshaling to/from JSON for communication
 // with clients
 type JsonGrid struct {
 	Solution Grid   `json:"solution"`
 	Status   string `json:"status"`
+	Unique   bool   `json:"unique"`           // True if the puzzle has exactly one solution
+	Solver   string `json:"solver,omitempty"` // Solver backend: "backtrack" (default) or "dlx"
+	Code     string `json:"code,omitempty"`   // Machine-readable error code.  See ErrorCode
+
+	// Grids of other sizes.  Size 0 or 9 is the standard grid in Solution.
+	// Any other Size is sent in Cels instead, with boxes of BoxRows by
+	// BoxCols cels.  The box shape defaults to ShapeForSize(Size).
+	Size    int       `json:"size,omitempty"`
+	BoxRows int       `json:"boxRows,omitempty"`
+	BoxCols int       `json:"boxCols,omitempty"`
+	Cels    []CelVals `json:"cels,omitempty"`
+
+	// Variant rules, if any
+	Variants
+
+	// Jigsaw regions replacing the boxes, if not nil.  See RegionMap
+	Regions RegionMap `json:"regions,omitempty"`
+
+	// Extra regions, each a list of cels holding each value at most once
+	Extra [][]Cel `json:"extra,omitempty"`
+
+	// Killer cages, if any.  See Cage
+	Cages []Cage `json:"cages,omitempty"`
+
+	// Thermometers, arrows, sandwiches and neighbour marks, if any.  See
+	// Constraints
+	Constraints
+
+	// Every clashing pair of cels when Code is "conflict".  See Validate
+	Conflicts []Conflict `json:"conflicts,omitempty"`
+}
+
+// Leaves Solution out of the JSON for a grid of another size, where it
+// would only be an empty standard grid beside the real one in Cels
+func (jGrid JsonGrid) MarshalJSON() ([]byte, error) {
+	type plain JsonGrid // The same fields, without this method
+	if jGrid.Size == 0 || jGrid.Size == GridSize {
+		return json.Marshal(plain(jGrid))
+	}
+	return json.Marshal(struct {
+		Solution *Grid `json:"solution,omitempty"`
+		plain
+	}{nil, plain(jGrid)})
 }
 
-//  CelVal method to verify the value is within range
+//  CelVal method to verify the value is within range for the standard
+//  grid, 0 to MaxVal (9).  Values in grids of other sizes are checked
+//  against the grid's own size when it is solved.
 func (val CelVal) IsValid() bool {
 	return val <= MaxVal
 }
 
 //
-//  Internal object describing each cel while iterating on a solution
-//  Note compiler init defaults are good so no explicit 'init' required
+//  Internal engine geometry.  Cels are numbered from 0, left to right
+//  and top to bottom.  On a grid of side n, units 0 to n-1 are the rows,
+//  n to 2n-1 the columns and 2n to 3n-1 the boxes, or the jigsaw regions
+//  that replace them.  Any regions added by variants follow, then the
+//  puzzle's own extra regions and killer cages.  A geometry is read-only
+//  once built.  Grids of the same shape and variants share one; puzzles
+//  with regions, extra regions, cages or constraints of their own get
+//  their own.
 //
-//  A cel can be in one of three states:
-//	1. Fixed:  Either pre-set by the game, or only one legal value based on the pre-set game.
-//	2. Blank with a list of possible legal candidate values.
-//	3. Temp: Temporary candidate value while searching for a valid solution
-//
-type cel struct {
-	fixed  bool     // True if pre-set by caller.  Value cannot be changed
-	temp   bool     // Value is a candidate solution for the current iteration
-	value  CelVal   // Current value
-	opList []CelVal // Track possible solution options for this cel
+const boxSize = 3
+
+type geometry struct {
+	shape    Shape
+	variants Variants
+	size     int      // Cels on a side, and the largest value
+	numCels  int      // Cels in the grid
+	maxVal   CelVal   // Largest legal value
+	allVals  valSet   // Values 1 through maxVal
+	units    []Unit   // Exported description of each unit
+	unitCels [][]int  // Cels in each unit
+	celUnits [][]int  // Units each cel belongs to, in unit order
+	peers    [][]int  // Other cels sharing a unit or a move rule with each cel
+	sees     [][]bool // True if two different cels share a unit or a move rule
+
+	moves []moveRule // Chess variant rules, or nil.  See variants.go
+
+	// Killer cages.  nil if there are none.  See killer.go
+	sums   []int        // Target sum of each unit, or 0 if it has none
+	combos [][][]valSet // Sets of different values, by count and sum
+
+	// Line and shape constraints.  nil if there are none.  See constraints.go
+	constraints    []constraint
+	celConstraints [][]conRef // Constraints covering each cel
+
+	// Board position of each cel of a multi-grid puzzle, or nil for a
+	// single grid.  See multi.go
+	board []Cel
 }
 
-//  Internal array of cels.  Holds the state of the solution while solving
-type grid [GridSize][GridSize]cel
+// Geometry of the standard 9x9 grid
+var classic = newGeometry(Shape{boxSize, boxSize}, rules{})
+
+//  Build the geometry for a shape and rules.  Any jigsaw regions or cages
+//  must already be checked.
 
-// Private cel method for setting to a fixed number
-func (cp *cel) setFixed(val CelVal) {
-	cp.fixed = true
-	cp.temp = false
-	cp.value = val
-	cp.opList = nil
+func newGeometry(shape Shape, r rules) *geometry {
+
+	size := shape.Size()
+	geo := &geometry{
+		shape:    shape,
+		variants: r.variants,
+		size:     size,
+		numCels:  size * size,
+		maxVal:   CelVal(size),
+		allVals:  (1<<(size+1) - 1) &^ 1,
+	}
+
+	regions := r.regions
+	kinds := [...]string{"row", "column", "box"}
+	if regions != nil {
+		kinds[2] = "region"
+	}
+	for unit := 0; unit < len(kinds)*size; unit++ {
+		geo.units = append(geo.units, Unit{kinds[unit/size], unit % size})
+		geo.unitCels = append(geo.unitCels, make([]int, 0, size))
+	}
+
+	// Cels go into each unit in cel order
+	boxesPerBand := size / shape.BoxCols
+	for row := 0; row < size; row++ {
+		for col := 0; col < size; col++ {
+			idx := row*size + col
+			box := (row/shape.BoxRows)*boxesPerBand + col/shape.BoxCols
+			if regions != nil {
+				box = regions[row][col]
+			}
+
+			geo.unitCels[row] = append(geo.unitCels[row], idx)
+			geo.unitCels[size+col] = append(geo.unitCels[size+col], idx)
+			geo.unitCels[2*size+box] = append(geo.unitCels[2*size+box], idx)
+		}
+	}
+
+	for _, vr := range r.variants.regions(shape) {
+		geo.units = append(geo.units, vr.unit)
+		geo.unitCels = append(geo.unitCels, vr.cels)
+	}
+	for i, cels := range r.extra {
+		geo.units = append(geo.units, Unit{"extra", i})
+		geo.unitCels = append(geo.unitCels, celIndexes(cels, size))
+	}
+
+	if r.cages != nil {
+		geo.sums = make([]int, len(geo.units), len(geo.units)+len(r.cages))
+		geo.combos = combosFor(size)
+	}
+	for i, cage := range r.cages {
+		geo.units = append(geo.units, Unit{"cage", i})
+		geo.unitCels = append(geo.unitCels, celIndexes(cage.Cels, size))
+		geo.sums = append(geo.sums, cage.Sum)
+	}
+
+	// Already checked, so cannot fail
+	geo.constraints, _ = r.constraints.build(size)
+	if r.variants.NonConsecutive {
+		geo.constraints = append(geo.constraints, neighbourRules(size, "nonconsecutive", notConsecutive, nil)...)
+	}
+	if geo.constraints != nil {
+		geo.celConstraints = make([][]conRef, geo.numCels)
+		for con, c := range geo.constraints {
+			for pos, idx := range c.cels() {
+				geo.celConstraints[idx] = append(geo.celConstraints[idx], conRef{con, pos})
+			}
+		}
+	}
+
+	geo.moves = r.variants.moves(size)
+	geo.link()
+	return geo
 }
 
-// Re-initialize a non-fixed cel.  Used when backtracking
-func (cp *cel) reInit() {
-	cp.fixed = false
-	cp.value = 0
-	cp.temp = false
-	cp.opList = nil
+//  Fill in the units of each cel, and the cels each cel sees through its
+//  units and any move rules
+
+func (geo *geometry) link() {
+
+	geo.celUnits = make([][]int, geo.numCels)
+	for unit, cels := range geo.unitCels {
+		for _, idx := range cels {
+			geo.celUnits[idx] = append(geo.celUnits[idx], unit)
+		}
+	}
+
+	geo.sees = make([][]bool, geo.numCels)
+	for idx := range geo.sees {
+		geo.sees[idx] = make([]bool, geo.numCels)
+	}
+	for _, cels := range geo.unitCels {
+		for _, a := range cels {
+			for _, b := range cels {
+				geo.sees[a][b] = a != b
+			}
+		}
+	}
+	for _, rule := range geo.moves {
+		for a, cels := range rule.cels {
+			for _, b := range cels {
+				geo.sees[a][b] = true
+			}
+		}
+	}
+	geo.peers = make([][]int, geo.numCels)
+	for a := 0; a < geo.numCels; a++ {
+		for b := 0; b < geo.numCels; b++ {
+			if geo.sees[a][b] {
+				geo.peers[a] = append(geo.peers[a], b)
+			}
+		}
+	}
 }
 
-// Set this cel to 'Temporarily fixed' for the current iteration.
-// Temp means it may be cleared when backtracking up to a higher recursion level
-func (cp *cel) setTemp(val CelVal) {
-	cp.fixed = false
-	cp.temp = true
-	cp.value = val
-	cp.opList = nil
+// Convert an internal cel index to its exported position
+func (geo *geometry) celOf(idx int) Cel {
+	if geo.board != nil {
+		return geo.board[idx]
+	}
+	return Cel{idx / geo.size, idx % geo.size}
 }
 
-func (cp *cel) setValue(val CelVal) {
-	cp.value = val
+// Convert exported positions to internal cel indexes, in the same order
+func celIndexes(cels []Cel, size int) []int {
+	idxs := make([]int, len(cels))
+	for i, cel := range cels {
+		idxs[i] = cel.Row*size + cel.Col
+	}
+	return idxs
 }
 
-func (cp *cel) setOptionList(ol []CelVal) {
-	cp.opList = ol
+// True if a unit has a cel for every value, so must hold each of them.
+// Other units only hold each value at most once
+func (geo *geometry) full(unit int) bool {
+	return len(geo.unitCels[unit]) == geo.size
 }
 
-// Get methods.  Supposedly not the "Go way" but it's good OO programming
+//  Set of cel values held as a bitmask.  Bit n is set if value n is
+//  in the set.  Used for both the candidate values of a cel and the
+//  values already placed in a unit.
+type valSet uint32
 
-func (cp *cel) isFixed() bool {
-	return cp.fixed
+func valBit(val CelVal) valSet {
+	return 1 << val
 }
 
-func (cp *cel) isTemp() bool {
-	return cp.temp
+func (vs valSet) count() int {
+	return bits.OnesCount32(uint32(vs))
 }
 
-func (cp *cel) getNumOptions() int {
-	return len(cp.opList)
+func (vs valSet) has(val CelVal) bool {
+	return vs&valBit(val) != 0
 }
 
-func (cp *cel) getOptionList() []CelVal {
-	return cp.opList
+//  Lowest value in the set.  The set must not be empty.
+//  Iterate over a set with:  for ; vs != 0; vs &^= valBit(vs.first())
+func (vs valSet) first() CelVal {
+	return CelVal(bits.TrailingZeros32(uint32(vs)))
 }
 
-// Internal function for determining if a map contains exactly one of each 9 digits
-// Per Sudoku rules, each row, column and 3x3 box must contain one of each digit.
+//
+//  Internal state of the puzzle while solving.
+//  Sized for its geometry by init, which leaves every cel blank.
+//
+//  Rather than keep an option list per cel, the grid keeps a mask per
+//  unit of the values already placed in it.  The options for a blank
+//  cel are the values missing from all of its units.  Placing or
+//  removing a value updates the masks of its units, so the search
+//  can backtrack in place without copying or allocating.
+//
+type grid struct {
+	geo    *geometry
+	value  []CelVal // Current value, or Blank
+	fixed  []bool   // True if pre-set by caller.  Value cannot be changed
+	used   []valSet // Values placed in each unit
+	sum    []int    // Total of the values placed in each unit
+	filled int      // Number of non-blank cels
+	nodes  int      // Search nodes visited.  Used to poll the context
+
+	// Options worked out by each constraint, and whether they are still
+	// current.  nil if there are none.  See constraints.go
+	conOpts  [][]valSet
+	conFresh []bool
+}
 
-func checkDigits(celMap map[CelVal]bool) bool {
-	if len(celMap) != GridSize {
-		// Can't contain exactly one of each value
-		return false
+// Set up a blank grid of the given geometry
+func (gp *grid) init(geo *geometry) {
+	*gp = grid{
+		geo:   geo,
+		value: make([]CelVal, geo.numCels),
+		fixed: make([]bool, geo.numCels),
+		used:  make([]valSet, len(geo.units)),
+		sum:   make([]int, len(geo.units)),
 	}
-
-	for val := MinVal; val <= MaxVal; val++ {
-		if !celMap[val] {
-			return false
+	if geo.constraints != nil {
+		gp.conOpts = make([][]valSet, len(geo.constraints))
+		for con, c := range geo.constraints {
+			gp.conOpts[con] = make([]valSet, len(c.cels()))
 		}
+		gp.conFresh = make([]bool, len(geo.constraints))
 	}
-	return true
 }
 
-// Private grid methods to check if a row, col, or box already has a given number
-// Will use when building up the solution option list for each cel
+// Number of search nodes between checks of the context.  Must be a power of 2
+const ctxPollInterval = 256
 
-func (gp *grid) inRow(val CelVal, row int) bool {
-	for col := 0; col < GridSize; col++ {
-		if val == gp[row][col].value {
-			return true
-		}
+// Private grid method for placing a value in a blank cel
+func (gp *grid) place(idx int, val CelVal) {
+	bit := valBit(val)
+	for _, unit := range gp.geo.celUnits[idx] {
+		gp.used[unit] |= bit
+		gp.sum[unit] += int(val)
 	}
-	return false
+	gp.value[idx] = val
+	gp.filled++
+	gp.staleConstraints(idx)
 }
 
-func (gp *grid) inCol(val CelVal, col int) bool {
-	for row := 0; row < GridSize; row++ {
-		if val == gp[row][col].value {
-			return true
-		}
+// Clear a cel placed with place.  Used when backtracking
+func (gp *grid) remove(idx int) {
+	bit := valBit(gp.value[idx])
+	for _, unit := range gp.geo.celUnits[idx] {
+		gp.used[unit] &^= bit
+		gp.sum[unit] -= int(gp.value[idx])
 	}
-	return false
+	gp.value[idx] = Blank
+	gp.filled--
+	gp.staleConstraints(idx)
 }
 
-func (gp *grid) inBox(val CelVal, row int, col int) bool {
-	top := row - (row % 3)
-	left := col - (col % 3)
+// Set of legal values for the cel, based on the values in its units,
+// the cels a chess move away, the sums of any cages and any line or
+// shape constraints.  Only meaningful for a blank cel
+
+func (gp *grid) options(idx int) valSet {
+	var inUnits valSet
+	for _, unit := range gp.geo.celUnits[idx] {
+		inUnits |= gp.used[unit]
+	}
+	for _, rule := range gp.geo.moves {
+		for _, near := range rule.cels[idx] {
+			inUnits |= valBit(gp.value[near])
+		}
+	}
+	opts := gp.geo.allVals &^ inUnits
 
-	for c := left; c < (left + 3); c++ {
-		for r := top; r < (top + 3); r++ {
-			if val == gp[r][c].value {
-				return true
+	if gp.geo.sums != nil {
+		for _, unit := range gp.geo.celUnits[idx] {
+			if gp.geo.sums[unit] != 0 {
+				opts &= gp.cageOptions(unit)
 			}
 		}
 	}
-	return false
+	if gp.geo.celConstraints != nil {
+		for _, ref := range gp.geo.celConstraints[idx] {
+			opts &= gp.constraintOptions(ref)
+		}
+	}
+	return opts
 }
 
-// Check if a row, column, or box has a legal config
+// Check if a unit has a legal config: one of each value and no blanks.
+// A unit with fewer cels than values needs no blanks and no repeats, and
+// a cage also needs the right sum.  A blank sets bit 0, which is not in
+// allVals.
 
-func (gp *grid) checkRow(row int) bool {
-	rm := make(map[CelVal]bool)
-	for col := 0; col < GridSize; col++ {
-		rm[gp[row][col].value] = true
+func (gp *grid) checkUnit(unit int) bool {
+	var seen valSet
+	for _, idx := range gp.geo.unitCels[unit] {
+		seen |= valBit(gp.value[idx])
 	}
-	return checkDigits(rm)
-}
-
-func (gp *grid) checkCol(col int) bool {
-	cm := make(map[CelVal]bool)
-	for row := 0; row < GridSize; row++ {
-		cm[gp[row][col].value] = true
+	if gp.geo.sums != nil && gp.geo.sums[unit] != 0 && gp.geo.sums[unit] != gp.sum[unit] {
+		return false
 	}
-	return checkDigits(cm)
-}
-
-//  Assumes passed the top left cel in a box
-func (gp *grid) checkBox(topRow int, leftCol int) bool {
-	bm := make(map[CelVal]bool)
-	for row := topRow; row < topRow+3; row++ {
-		for col := leftCol; col < leftCol+3; col++ {
-			bm[gp[row][col].value] = true
-		}
+	if !gp.geo.full(unit) {
+		return !seen.has(Blank) && seen.count() == len(gp.geo.unitCels[unit])
 	}
-	return checkDigits(bm)
+	return seen == gp.geo.allVals
 }
 
 //  Check to determine if a board is solved.
 
 func (gp *grid) checkGrid() bool {
-	for i := 0; i < GridSize; i++ {
-		if !gp.checkRow(i) {
-			return false
-		}
-		if !gp.checkCol(i) {
+	for unit := range gp.geo.units {
+		if !gp.checkUnit(unit) {
 			return false
 		}
 	}
-	for row := 0; row < GridSize; row += 3 {
-		for col := 0; col < GridSize; col += 3 {
-			if !gp.checkBox(row, col) {
-				return false
+
+	// The rows are full, so no cel is blank here
+	for _, rule := range gp.geo.moves {
+		for a, cels := range rule.cels {
+			for _, b := range cels {
+				if gp.value[a] == gp.value[b] {
+					return false
+				}
 			}
 		}
 	}
+	for _, c := range gp.geo.constraints {
+		if !c.holds(gp) {
+			return false
+		}
+	}
 	return true
 }
 
-// Build the option list for the cel at the row and column specified
-// Returns the number of options found.
-// Assumes this cel is not fixed.  Should be confirmed by the caller
+//  Find the first/next blank cel with the minimum number of solution options.
+//  When the program recurses down through the solution tree, it will
+//  start with this cel as the path with the highest probability of
+//  leading to a successful solution.
+//  Returns -1 if there are no blank cels.  Stops looking early on a cel
+//  with 0 or 1 options since nothing can beat it.
 
-func (gp *grid) buildOptionList(row int, col int) int {
+func (gp *grid) findMinOptionCel() (minIdx int, minOpts valSet) {
 
-	var opList []CelVal
+	minIdx = -1
+	minOptCnt := gp.geo.size + 1
 
-	for val := MinVal; val <= MaxVal; val++ {
-		if !gp.inRow(val, row) {
-			if !gp.inCol(val, col) {
-				if !gp.inBox(val, row, col) {
-					opList = append(opList, val)
-				}
-			}
+	for idx := 0; idx < gp.geo.numCels; idx++ {
+		if gp.value[idx] != Blank {
+			continue
 		}
-	}
-	gp[row][col].setOptionList(opList)
-	return len(opList)
-}
 
-// Clear option lists and Temp cels.  Used when backtracking
-
-func (gp *grid) clearTempOptions() {
-
-	for row := 0; row < GridSize; row++ {
-		for col := 0; col < GridSize; col++ {
-			if !gp[row][col].isFixed() {
-				gp[row][col].reInit()
+		opts := gp.options(idx)
+		if cnt := opts.count(); cnt < minOptCnt {
+			minIdx, minOpts, minOptCnt = idx, opts, cnt
+			if cnt <= 1 {
+				break
 			}
 		}
 	}
+	return minIdx, minOpts
 }
 
-//  Find the first/next cel wth the minimum number of solution options.
-//  When the program recurses down through the soluiton tree, it will
-//  start with this cel as the path with the highest probability of
-//  leading to a successful solution
+//  Find a value with only one place left in one of the full units.  It
+//  must go there, which can cut the search short when every blank cel
+//  has two or more options, as in a grid that starts blank.  Returns
+//  the cel with the value as its only option, or the cel with no options
+//  if a unit has no place left for a value.  Returns -1 if neither is
+//  found.  Cages and other units with fewer cels than values are
+//  skipped since they need not hold every value.
 
-func (gp *grid) findMinOptionCel() (minCelP *cel) {
+func (gp *grid) findHiddenSingle() (int, valSet) {
 
-	var minOptCnt int = int(MaxVal)
+	for unit, cels := range gp.geo.unitCels {
+		if !gp.geo.full(unit) {
+			continue
+		}
 
-	for row := 0; row < GridSize; row++ {
-		for col := 0; col < GridSize; col++ {
-			if gp[row][col].isFixed() {
-				continue
-			}
-			if gp[row][col].isTemp() {
-				continue
+		// Values with a place in the unit, and with more than one
+		var once, twice valSet
+		blank := -1
+		for _, idx := range cels {
+			if gp.value[idx] == Blank {
+				opts := gp.options(idx)
+				twice |= once & opts
+				once |= opts
+				blank = idx
 			}
+		}
 
-			if gp[row][col].getNumOptions() < minOptCnt {
-				minCelP = &gp[row][col]
-				minOptCnt = gp[row][col].getNumOptions()
+		missing := gp.geo.allVals &^ gp.used[unit]
+		if missing&^once != 0 {
+			return blank, 0
+		}
+		if single := missing &^ twice; single != 0 {
+			val := single.first()
+			for _, idx := range cels {
+				if gp.value[idx] == Blank && gp.options(idx).has(val) {
+					return idx, valBit(val)
+				}
 			}
 		}
 	}
-
-	return minCelP
+	return -1, 0
 }
 
 //  As a first step in solving a puzzle, solve for cels that only have one legal
@@ -298,7 +554,7 @@ func (gp *grid) findMinOptionCel() (minCelP *cel) {
 //  The simplest puzzles can be solved through this direct calculation
 //  Return true if solved.
 //
-//  Assumes the client supplied cels are populated and others are initialized to 0
+//  Assumes the client supplied cels are populated and others are blank
 //  Returns an error if any cels have no legal solution options
 
 func (gp *grid) firstPassSolve() (bool, error) {
@@ -308,134 +564,299 @@ func (gp *grid) firstPassSolve() (bool, error) {
 	for changes {
 		changes = false
 
-		for row := 0; row < GridSize; row++ {
-			for col := 0; col < GridSize; col++ {
-				celPtr := &gp[row][col]
-
-				if celPtr.isFixed() {
-					continue
-				}
-
-				// for this cel, build its option list.
-				switch gp.buildOptionList(row, col) {
-				case 0:
-					// No options, this is an illegal initial config so return error
-					return false, fmt.Errorf("illegal config.  No legal value for cel %d, %d", row, col)
-				case 1:
-					// If only one option for this cel.  Make it fixed
-					val := celPtr.opList[0]
-					celPtr.setFixed(val)
-					changes = true // Repeat with this cel now solved
-				}
+		for idx := 0; idx < gp.geo.numCels; idx++ {
+			if gp.value[idx] != Blank {
+				continue
 			}
-			if changes {
-				// Restart at cel 0,0 to recalculate new (shorter) option lists
-				break
+
+			opts := gp.options(idx)
+			switch opts.count() {
+			case 0:
+				// No options, this is an illegal initial config so return error
+				cel := gp.geo.celOf(idx)
+				return false, &CelError{Err: ErrNoCandidates, Row: cel.Row, Col: cel.Col}
+			case 1:
+				// If only one option for this cel, place it.  The unit
+				// masks are updated so later cels see it right away
+				gp.place(idx, opts.first())
+				changes = true
 			}
 		}
 	}
 
-	//  If every cel is fixed, the puzzle is solved
-	for row := 0; row < GridSize; row++ {
-		for col := 0; col < GridSize; col++ {
-			if !gp[row][col].isFixed() {
-				return false, nil
-			}
+	//  If every cel is filled, the puzzle is solved
+	return gp.filled == gp.geo.numCels, nil
+}
+
+//  Takes a Sudoku puzzle that has been initialized with fixed values.
+//  Solves for the cels that have multiple solution options
+//
+//  The algorithm starts with the first cel with the minimum number
+//  of solution options, or with a value that has only one place left in
+//  a unit if that cel has more than one, places each candidate in turn,
+//  then recursively tries to solve for the remaining cels.  If no
+//  solution found, removes the value and tries the next candidate.
+//
+//  visit is called with each solution found.  It must copy out anything
+//  it needs since the grid is unwound on the way back up.  Returning
+//  false from visit stops the search.
+//
+//  Returns false if the search was stopped, either by visit or because the
+//  context was canceled or timed out.  The error reports the latter.
+
+func (gp *grid) recursiveSolve(ctx context.Context, visit func(gp *grid) bool) (bool, error) {
+
+	gp.nodes++
+	if gp.nodes%ctxPollInterval == 0 {
+		if err := ctxError(ctx); err != nil {
+			return false, err
 		}
 	}
 
-	return true, nil // Solved!
+	idx, opts := gp.findMinOptionCel()
+	if idx < 0 {
+		// No blank cels left
+		if gp.checkGrid() {
+			return visit(gp), nil
+		}
+		return true, nil
+	}
+	if opts.count() > 1 {
+		if hidden, single := gp.findHiddenSingle(); hidden >= 0 {
+			idx, opts = hidden, single
+		}
+	}
+
+	// A cel with no options means a dead end; the loop does not run
+	for ; opts != 0; opts &^= valBit(opts.first()) {
+		gp.place(idx, opts.first())
+		more, err := gp.recursiveSolve(ctx, visit)
+		gp.remove(idx)
+
+		if !more {
+			return false, err
+		}
+	}
+	//  Tried all options.  Return back to the next higher level
+	return true, nil
 }
 
-//  Function to recalculate option lists for all the blank cels after
-//  setting a temporary trial value in one cel.
+//  Internal function to convert a done context into the matching
+//  solver error.  Returns nil if the context is still live.
 
-func (gp *grid) recalcOptionLists() bool {
+func ctxError(ctx context.Context) error {
 
-	var recalc bool = true
+	switch ctx.Err() {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		return ErrTimeout
+	default:
+		return ErrCanceled
+	}
+}
 
-	for recalc {
+//  A caller's grid the engine can load from and store to.
+//  Implemented by *Grid and *SizedGrid.
 
-		recalc = false
+type celGrid interface {
+	geo() (*geometry, error) // Geometry, or error if the grid is malformed
+	cel(row, col int) CelVal
+	setCel(row, col int, val CelVal)
+}
 
-		for row := 0; row < GridSize; row++ {
-			for col := 0; col < GridSize; col++ {
-				if gp[row][col].isFixed() {
-					continue
-				}
-				if gp[row][col].isTemp() {
-					continue
-				}
+func (configP *Grid) geo() (*geometry, error) {
+	return classic, nil
+}
 
-				switch gp.buildOptionList(row, col) {
-				case 0:
-					// No options for this cel
-					// Need to backtrack and try the next temp value
-					return false
+func (configP *Grid) cel(row, col int) CelVal {
+	return configP[row][col]
+}
 
-				case 1:
-					// Only one option, set to 'Temp fixed' for the current iteration
-					// Since we have set a new value in a cel, recalc all option lists
-					val := gp[row][col].opList[0]
-					gp[row][col].setTemp(val)
-					recalc = true
-					break
-				}
+func (configP *Grid) setCel(row, col int, val CelVal) {
+	configP[row][col] = val
+}
+
+//  Internal function to load the caller's grid into the solver state.
+//  Returns error if the grid is malformed, if any initializers are out
+//  of range, if a value is repeated within a unit or a chess move apart,
+//  or if the values break a cage sum or a constraint
+
+func (gp *grid) load(configP celGrid) error {
+
+	geo, err := configP.geo()
+	if err != nil {
+		return err
+	}
+	gp.init(geo)
+
+	for idx := 0; idx < geo.numCels; idx++ {
+		cel := geo.celOf(idx)
+		val := configP.cel(cel.Row, cel.Col)
+		if val > geo.maxVal {
+			// Parameter out of range
+			return &CelError{Err: ErrOutOfRange, Row: cel.Row, Col: cel.Col}
+		}
+		if val == Blank {
+			// Left blank by init
+			continue
+		}
+
+		for _, unit := range geo.celUnits[idx] {
+			if gp.used[unit].has(val) {
+				return &CelError{Err: ErrConflict, Row: cel.Row, Col: cel.Col, Unit: geo.units[unit]}
 			}
-			if recalc {
-				break // Restart at cel 0, 0
+		}
+		for _, rule := range geo.moves {
+			for _, near := range rule.cels[idx] {
+				if gp.value[near] == val {
+					return &CelError{Err: ErrConflict, Row: cel.Row, Col: cel.Col, Unit: rule.unit}
+				}
 			}
 		}
+		gp.place(idx, val)
+		gp.fixed[idx] = true
 	}
-	return true
+	if err := gp.checkCageSums(); err != nil {
+		return err
+	}
+	return gp.checkConstraints()
 }
 
-//  Takes a Sudoku puzzle that has been initialized with fixed values
-//  and initial option lists for each cel.
-//  Solves for the cels that have multiple solution options
+//  Internal function to copy the solver state back out to the caller's
+//  grid, which must have the same geometry
+
+func (gp *grid) store(configP celGrid) {
+	for idx := 0; idx < gp.geo.numCels; idx++ {
+		cel := gp.geo.celOf(idx)
+		configP.setCel(cel.Row, cel.Col, gp.value[idx])
+	}
+}
+
+//  Internal front end shared by every solver backend.
+//  Loads the puzzle, verifies it meets Sudoku rules and solves the cels
+//  that only have one legal option.  Backends search from there, so they
+//  all report the same errors for an invalid puzzle.
+//  Returns true if the puzzle is already solved.
+
+func (gp *grid) prepare(ctx context.Context, configP celGrid) (bool, error) {
+
+	// Initialize.  Return error if any intializers are out of range
+	if err := gp.load(configP); err != nil {
+		return false, err
+	}
+
+	if err := ctxError(ctx); err != nil {
+		return false, err
+	}
+
+	// Verify supplied config meets Sudoku rules.
+	// Also set any cels with only one solution option.
+	return gp.firstPassSolve()
+}
+
+//  Interface implemented by each solver backend.
 //
-//  The algorithm starts with the first cel with the minimum number
-//  of solution options, sets that as fixed with each candidate value,
-//  then recursively tries to solve for the remaining cels.  If no
-//  solution found, backtracks and tries the next candidate value.
+//  Solutions calls fn with each solution of the puzzle, in search order.
+//  It stops once limit solutions have been delivered, or when fn returns
+//  false.  A limit of 0 or less means no limit.  The caller's Grid is not
+//  modified.  Returns the number of solutions passed to fn, and error for
+//  an invalid puzzle or when the context is canceled or times out.
+//  An unsolvable puzzle is not an error; fn is simply never called.
 //
-//  Returns true of puzzle solved
+//  SizedSolutions is the same for a grid of any size.  See sizes.go
+//  MultiSolutions is the same for several overlapping grids solved
+//  together.  See multi.go
+
+type Solver interface {
+	Solutions(ctx context.Context, configP *Grid, limit int, fn func(soln Grid) bool) (int, error)
+	SizedSolutions(ctx context.Context, configP *SizedGrid, limit int, fn func(soln SizedGrid) bool) (int, error)
+	MultiSolutions(ctx context.Context, configP *MultiGrid, limit int, fn func(soln MultiGrid) bool) (int, error)
+}
+
+// The available solver backends
+var Backtrack Solver = backtrackSolver{}
+var DancingLinks Solver = dlxSolver{}
 
-func (gp *grid) recursiveSolve() bool {
+// Default backend used by Solve, Solutions and friends
+var DefaultSolver Solver = Backtrack
 
-	curCel := gp.findMinOptionCel()
-	optionList := curCel.getOptionList()
-	if len(optionList) == 0 {
-		return gp.checkGrid()
+// Look up a solver backend by the name used in the JSON API.
+// An empty name selects the default.
+
+func SolverByName(name string) (Solver, error) {
+
+	switch name {
+	case "":
+		return DefaultSolver, nil
+	case "backtrack":
+		return Backtrack, nil
+	case "dlx":
+		return DancingLinks, nil
 	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownSolver, name)
+}
 
-	for _, celVal := range optionList {
-		curCel.setFixed(celVal)
+//  The bitmask backtracking engine in this file
 
-		if !gp.recalcOptionLists() {
-			// Some cels have no legal option
-			// try the next value
-			gp.clearTempOptions()
-			continue
-		}
+type backtrackSolver struct{}
 
-		// Options lists are recalculated
-		// Any cels with only one option are set to Temp Fixed
-		// See if we have a solution
-		if gp.checkGrid() {
-			return true
-		}
+func (s backtrackSolver) Solutions(ctx context.Context, configP *Grid, limit int, fn func(soln Grid) bool) (int, error) {
 
-		// Else, recurse to look for a solution with the current cel fixed
-		if gp.recursiveSolve() {
-			return true
-		}
+	count := 0
+	err := s.search(ctx, configP, func(gp *grid) bool {
+		var soln Grid
+		gp.store(&soln)
+		count++
+		return fn(soln) && (limit <= 0 || count < limit)
+	})
+	return count, err
+}
+
+func (s backtrackSolver) SizedSolutions(ctx context.Context, configP *SizedGrid, limit int, fn func(soln SizedGrid) bool) (int, error) {
+
+	count := 0
+	err := s.search(ctx, configP, func(gp *grid) bool {
+		soln := configP.blank()
+		gp.store(&soln)
+		count++
+		return fn(soln) && (limit <= 0 || count < limit)
+	})
+	return count, err
+}
+
+func (s backtrackSolver) MultiSolutions(ctx context.Context, configP *MultiGrid, limit int, fn func(soln MultiGrid) bool) (int, error) {
+
+	count := 0
+	err := s.search(ctx, configP, func(gp *grid) bool {
+		soln := configP.blank()
+		gp.store(&soln)
+		count++
+		return fn(soln) && (limit <= 0 || count < limit)
+	})
+	return count, err
+}
+
+//  Search shared by both entry points.  Calls visit with each solution
+
+func (backtrackSolver) search(ctx context.Context, configP celGrid, visit func(gp *grid) bool) error {
+
+	// Grid structure for maintaining state while solving
+	var gp grid
+
+	solved, err := gp.prepare(ctx, configP)
+	if err != nil {
+		return err
 	}
-	//  Tried all options, no solution found.
-	//  Re-init this cel and return back to the next higher level
-	gp.clearTempOptions()
-	curCel.reInit()
-	return false
+
+	// The simplest puzzles can be solved above.
+	if solved {
+		visit(&gp)
+		return nil
+	}
+
+	_, err = gp.recursiveSolve(ctx, visit)
+	return err
 }
 
 //  The public entry point for solving a puzzle.
@@ -446,76 +867,180 @@ func (gp *grid) recursiveSolve() bool {
 //	initial config that violates Sudoku rules
 //	unsolvable puzzle
 //  Otherwise, populates with a solved Grid
+//  See errors.go for the errors returned
 
 func Solve(configP *Grid) error {
 
-	// Allocate a grid structure for maintaining state while solving
-	// Note default compiler init values are fine for empty cels
-	var solnGrid grid
-	var gp *grid = &solnGrid
-
-	// Internal anonymous function to copy the result into the return Grid
-	// used in multiple places so declare as anonymous func.
-	var cpOut = func(configP *Grid, gp *grid) {
-		for row := 0; row < GridSize; row++ {
-			for col := 0; col < GridSize; col++ {
-				configP[row][col] = gp[row][col].value
-			}
-		}
-	}
+	return SolveContext(context.Background(), configP)
+}
 
-	// Initialize.  Return error if any intializers are out of range
-	for row := 0; row < GridSize; row++ {
-		for col := 0; col < GridSize; col++ {
-			if !configP[row][col].IsValid() {
-				// Parameter out of range
-				err := fmt.Errorf("illegal value for cel %d, %d", row, col)
-				return err
-			}
-			if configP[row][col] == Blank {
-				// Compiler init is fine
-				continue
-			}
-			gp[row][col].setFixed(configP[row][col])
-		}
-	}
+//  Same as Solve, but gives up when the context is canceled or its
+//  deadline passes.  Returns ErrCanceled or ErrTimeout in that case and
+//  the caller's Grid is left unchanged.
 
-	// Verify supplied config meets Sudoku rules.
-	// Also set any cels with only one solution option as fixed.
-	// Also builds initial option lists for each cel
-	solved, err := gp.firstPassSolve()
+func SolveContext(ctx context.Context, configP *Grid) error {
+
+	return SolveWith(ctx, DefaultSolver, configP)
+}
+
+//  Same as SolveContext, using the given solver backend
+
+func SolveWith(ctx context.Context, solver Solver, configP *Grid) error {
+
+	var soln Grid
+
+	// Stop at the first solution
+	count, err := solver.Solutions(ctx, configP, 1, func(s Grid) bool {
+		soln = s
+		return false
+	})
 	if err != nil {
 		return err
 	}
 
-	// The simplest puzzles can be solved above.
-	if solved {
-		cpOut(configP, gp)
-		return nil
-	}
-
-	if !gp.recursiveSolve() {
-		err := fmt.Errorf("No solution found.")
-		return err
+	if count == 0 {
+		return ErrUnsolvable
 	}
 
 	// Copy the solution and return solution to caller
-	cpOut(configP, gp)
+	*configP = soln
 	return nil
 }
 
+//  Public entry point for enumerating every solution of a puzzle.
+//  Calls fn with each solution found, in search order.  Stops once limit
+//  solutions have been delivered, or when fn returns false.  A limit of 0
+//  or less means no limit, which can take a very long time for a sparsely
+//  populated grid.  The caller's Grid is not modified.
+//
+//  Returns the number of solutions passed to fn.
+//  Returns the same errors as Solve for an invalid puzzle.  An unsolvable
+//  puzzle is not an error; fn is simply never called.
+
+func Solutions(configP *Grid, limit int, fn func(soln Grid) bool) (int, error) {
+
+	return DefaultSolver.Solutions(context.Background(), configP, limit, fn)
+}
+
+//  Public entry point for counting the solutions of a puzzle.
+//  Keeps searching after the first solution is found and stops once
+//  limit solutions have been counted.  A limit of 0 or less means no limit.
+//  Returns the same errors as Solutions.
+
+func CountSolutions(configP *Grid, limit int) (int, error) {
+
+	return CountSolutionsWith(context.Background(), DefaultSolver, configP, limit)
+}
+
+//  Same as CountSolutions, using the given solver backend
+
+func CountSolutionsWith(ctx context.Context, solver Solver, configP *Grid, limit int) (int, error) {
+
+	return solver.Solutions(ctx, configP, limit, func(Grid) bool {
+		return true
+	})
+}
+
+//  Count the solutions of either kind of grid with the default solver
+
+func countSolutions(ctx context.Context, configP celGrid, limit int) (int, error) {
+
+	if sg, ok := configP.(*SizedGrid); ok {
+		return CountSolutionsSizedWith(ctx, DefaultSolver, sg, limit)
+	}
+	return CountSolutionsWith(ctx, DefaultSolver, configP.(*Grid), limit)
+}
+
+//  Returns true if the puzzle has exactly one solution.
+//  Only needs to search far enough to find a second solution.
+
+func IsUnique(configP *Grid) (bool, error) {
+
+	count, err := CountSolutions(configP, 2)
+	if err != nil {
+		return false, err
+	}
+	return count == 1, nil
+}
+
 // JSON Solve
 // Takes a Json Grid structure.
-// Calls Solve and copies status into the JsonGrid struct
+// Calls Solve and copies status into the JsonGrid struct.  On error,
+// Code holds a machine-readable code for the error, and Conflicts
+// lists every clash for an illegal config.
 // If a solution is found, Solve copies directly into the
-// Solution grid in the JsonGrid struct
+// Solution grid in the JsonGrid struct, and Unique reports
+// whether it is the only solution.
 
 func Jsolve(jGridP *JsonGrid) {
 
-	if err := Solve(&jGridP.Solution); err != nil {
+	JsolveContext(context.Background(), jGridP)
+}
+
+// Same as Jsolve, but gives up when the context is canceled or
+// its deadline passes, and reports that in the Status and Code fields.
+// Uses the solver backend named in the Solver field.
+
+func JsolveContext(ctx context.Context, jGridP *JsonGrid) {
+
+	jGridP.Unique = false
+	jGridP.Code = ""
+	jGridP.Conflicts = nil
+
+	solver, err := SolverByName(jGridP.Solver)
+	if err != nil {
+		jGridP.Status = fmt.Sprintf("%v", err)
+		jGridP.Code = ErrorCode(err)
+		return
+	}
+
+	// Both standard and other sizes are solved as a SizedGrid
+	shape, sized, err := jGridP.shape()
+	if err != nil {
 		jGridP.Status = fmt.Sprintf("%v", err)
+		jGridP.Code = ErrorCode(err)
+		return
+	}
+	puzzle := SizedGrid{Shape: shape, Cels: jGridP.Cels}
+	if !sized {
+		puzzle = SizedGridOf(&jGridP.Solution)
+	}
+	puzzle.Variants = jGridP.Variants
+	puzzle.Regions = jGridP.Regions
+	puzzle.Extra = jGridP.Extra
+	puzzle.Constraints = jGridP.Constraints
+	puzzle.Cages = jGridP.Cages
+	soln := puzzle
+
+	if err := SolveSizedWith(ctx, solver, &soln); err != nil {
+		jGridP.Status = fmt.Sprintf("%v", err)
+		jGridP.Code = ErrorCode(err)
+
+		// Solve only reports the first clash.  List them all
+		if errors.Is(err, ErrConflict) {
+			jGridP.Conflicts, _ = ValidateSized(&puzzle)
+		}
+		return
+	}
+
+	// Solve succeeded, so the puzzle is valid and can only fail here
+	// if interrupted
+	count, err := CountSolutionsSizedWith(ctx, solver, &puzzle, 2)
+	if err != nil {
+		jGridP.Status = fmt.Sprintf("%v", err)
+		jGridP.Code = ErrorCode(err)
+		return
+	}
+
+	if sized {
+		jGridP.Cels = soln.Cels
+		jGridP.BoxRows, jGridP.BoxCols = shape.BoxRows, shape.BoxCols
 	} else {
-		jGridP.Status = fmt.Sprintf("Success")
+		for row := range jGridP.Solution {
+			copy(jGridP.Solution[row][:], soln.Cels[row])
+		}
 	}
+	jGridP.Status = fmt.Sprintf("Success")
+	jGridP.Unique = count == 1
 	return
 }
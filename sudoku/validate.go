@@ -0,0 +1,115 @@
+//
+// Copyright 2020, 2021 Kenneth J. Gibson
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//
+// Validation of a submitted grid.
+// Solve stops at the first problem it finds, and that is often a blank
+// cel some distance from the actual mistake.  Validate instead lists
+// every repeated value in every unit so a client can highlight the
+// offending cels.
+//
+
+package sudoku
+
+// Position of a cel in the grid
+type Cel struct {
+	Row int `json:"row"`
+	Col int `json:"col"`
+}
+
+// One pair of cels in a unit holding the same value.  A value repeated
+// three times in a unit is reported as three pairs.
+type Conflict struct {
+	Unit  Unit   `json:"unit"`
+	Value CelVal `json:"value"`
+	Cels  [2]Cel `json:"cels"`
+}
+
+//  Public entry point for checking a grid against the Sudoku rules.
+//  Returns every pair of clashing cels, by unit: rows first, then columns,
+//  boxes and any variant regions, then pairs a chess move apart under a
+//  chess variant.  Returns an empty list for a legal grid.
+//  Blank cels are ignored; this does not check that the grid can be solved.
+//  Returns ErrOutOfRange if any cel holds an illegal value.
+
+func Validate(configP *Grid) ([]Conflict, error) {
+
+	return validate(configP)
+}
+
+//  Same as Validate for a grid of any size or with variant rules.  Also
+//  returns ErrBadSize for a malformed grid.
+
+func ValidateSized(configP *SizedGrid) ([]Conflict, error) {
+
+	return validate(configP)
+}
+
+func validate(configP celGrid) ([]Conflict, error) {
+
+	var conflicts []Conflict
+
+	geo, err := configP.geo()
+	if err != nil {
+		return nil, err
+	}
+
+	for row := 0; row < geo.size; row++ {
+		for col := 0; col < geo.size; col++ {
+			if configP.cel(row, col) > geo.maxVal {
+				return nil, &CelError{Err: ErrOutOfRange, Row: row, Col: col}
+			}
+		}
+	}
+
+	value := func(idx int) CelVal {
+		return configP.cel(idx/geo.size, idx%geo.size)
+	}
+	for unit, cels := range geo.unitCels {
+		for i, idx := range cels {
+			val := value(idx)
+			if val == Blank {
+				continue
+			}
+			for _, other := range cels[i+1:] {
+				if value(other) == val {
+					conflicts = append(conflicts, Conflict{
+						Unit:  geo.units[unit],
+						Value: val,
+						Cels:  [2]Cel{geo.celOf(idx), geo.celOf(other)},
+					})
+				}
+			}
+		}
+	}
+
+	// Each pair a move apart is listed under both cels, so only report it
+	// from the first
+	for _, rule := range geo.moves {
+		for idx, cels := range rule.cels {
+			for _, other := range cels {
+				if val := value(idx); val != Blank && other > idx && value(other) == val {
+					conflicts = append(conflicts, Conflict{
+						Unit:  rule.unit,
+						Value: val,
+						Cels:  [2]Cel{geo.celOf(idx), geo.celOf(other)},
+					})
+				}
+			}
+		}
+	}
+	return conflicts, nil
+}
@@ -0,0 +1,160 @@
+//
+// Copyright 2020, 2021 Kenneth J. Gibson
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//
+// Variant rules.
+// A variant adds regions to the rows, columns and boxes of the grid.
+// Each extra region must also hold each value at most once, or exactly
+// once if it has a cel for every value.  The regions become units of
+// the engine geometry, so the solvers, the validator and the logical
+// techniques all respect them without knowing which variant is in use.
+//
+// Regions may overlap the boxes and each other.  Besides the fixed
+// regions of the named variants, a puzzle can bring its own list of
+// extra regions.
+//
+// The chess variants work differently.  They forbid the same value in
+// any two cels a knight's or king's move apart, which is not a region.
+// The geometry lists those cels for each cel instead, and counts them as
+// peers, so the logical techniques still see them.
+//
+// The non-consecutive rule relates the values of neighbouring cels
+// rather than forbidding a repeat, so it becomes a constraint on each
+// pair of neighbours, like an unmarked pair under negative Kropki.  See
+// pairs.go.
+//
+
+package sudoku
+
+import (
+	"fmt"
+)
+
+// Extra rules on top of the standard ones.  The zero value is standard
+// Sudoku.  Sent in JSON alongside the grid
+type Variants struct {
+	Diagonals  bool `json:"diagonals,omitempty"`  // Sudoku X: both main diagonals hold each value once
+	Windows    bool `json:"windows,omitempty"`    // Hyper Sudoku: the windows between the boxes hold each value once
+	AntiKnight bool `json:"antiKnight,omitempty"` // No value repeated a knight's move apart
+	AntiKing   bool `json:"antiKing,omitempty"`   // No value repeated a king's move apart
+
+	NonConsecutive bool `json:"nonConsecutive,omitempty"` // No consecutive values side by side or one above the other
+}
+
+// An extra region added by a variant
+type region struct {
+	unit Unit
+	cels []int
+}
+
+//  The regions the variants add to a grid of the given shape.
+//  Diagonal 0 runs from top left to bottom right, and diagonal 1 from
+//  top right to bottom left.  Windows are the size of a box and sit one
+//  cel in from the boxes around them, so a 9x9 grid has four, with their
+//  top left cels at row and column 1 or 5.  They are numbered left to
+//  right and top to bottom.
+
+func (v Variants) regions(shape Shape) []region {
+
+	var regions []region
+	size := shape.Size()
+
+	if v.Diagonals {
+		down, up := make([]int, size), make([]int, size)
+		for i := 0; i < size; i++ {
+			down[i] = i*size + i
+			up[i] = i*size + size - 1 - i
+		}
+		regions = append(regions, region{Unit{"diagonal", 0}, down}, region{Unit{"diagonal", 1}, up})
+	}
+
+	if v.Windows {
+		n := 0
+		for top := 1; top+shape.BoxRows < size; top += shape.BoxRows + 1 {
+			for left := 1; left+shape.BoxCols < size; left += shape.BoxCols + 1 {
+				var cels []int
+				for row := top; row < top+shape.BoxRows; row++ {
+					for col := left; col < left+shape.BoxCols; col++ {
+						cels = append(cels, row*size+col)
+					}
+				}
+				regions = append(regions, region{Unit{"window", n}, cels})
+				n++
+			}
+		}
+	}
+	return regions
+}
+
+// Cels a chess move apart under a variant, which may not hold the same
+// value
+type moveRule struct {
+	unit Unit    // Reported for a clash, e.g. {"knight", 0}
+	cels [][]int // Cels a move away from each cel
+}
+
+//  The move rules the variants add to a grid of the given side.
+
+func (v Variants) moves(size int) []moveRule {
+
+	var rules []moveRule
+
+	add := func(kind string, steps [][2]int) {
+		rule := moveRule{Unit{kind, 0}, make([][]int, size*size)}
+		for idx := range rule.cels {
+			row, col := idx/size, idx%size
+			for _, step := range steps {
+				r, c := row+step[0], col+step[1]
+				if r >= 0 && r < size && c >= 0 && c < size {
+					rule.cels[idx] = append(rule.cels[idx], r*size+c)
+				}
+			}
+		}
+		rules = append(rules, rule)
+	}
+
+	if v.AntiKnight {
+		add("knight", [][2]int{{-2, -1}, {-2, 1}, {-1, -2}, {-1, 2}, {1, -2}, {1, 2}, {2, -1}, {2, 1}})
+	}
+	if v.AntiKing {
+		add("king", [][2]int{{-1, -1}, {-1, 0}, {-1, 1}, {0, -1}, {0, 1}, {1, -1}, {1, 0}, {1, 1}})
+	}
+	return rules
+}
+
+//  Check a puzzle's own extra regions against a grid of the given side.
+//  Each must have from 1 to size cels, all on the grid and none twice.
+//  Returns ErrBadRegions describing the first problem found.
+
+func checkExtra(extra [][]Cel, size int) error {
+
+	for i, cels := range extra {
+		if len(cels) == 0 || len(cels) > size {
+			return fmt.Errorf("%w: extra region %d has %d cels", ErrBadRegions, i, len(cels))
+		}
+		seen := make(map[Cel]bool)
+		for _, cel := range cels {
+			if cel.Row < 0 || cel.Row >= size || cel.Col < 0 || cel.Col >= size {
+				return fmt.Errorf("%w: extra region %d cel %d, %d is off the grid", ErrBadRegions, i, cel.Row, cel.Col)
+			}
+			if seen[cel] {
+				return fmt.Errorf("%w: extra region %d has cel %d, %d twice", ErrBadRegions, i, cel.Row, cel.Col)
+			}
+			seen[cel] = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,430 @@
+// "go test" program for variant rules
+
+package sudoku
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// True if neither main diagonal repeats a value
+func diagonalsHold(sg *SizedGrid) bool {
+	size := sg.Size()
+	var down, up valSet
+	for i := 0; i < size; i++ {
+		down |= valBit(sg.Cels[i][i])
+		up |= valBit(sg.Cels[i][size-1-i])
+	}
+	return down.count() == size && up.count() == size && !down.has(Blank) && !up.has(Blank)
+}
+
+// True if no region repeats a value, and none holds a blank
+func regionsHold(sg *SizedGrid, regions []region) bool {
+	for _, r := range regions {
+		var seen valSet
+		for _, idx := range r.cels {
+			seen |= valBit(sg.Cels[idx/sg.Size()][idx%sg.Size()])
+		}
+		if seen.count() != len(r.cels) || seen.has(Blank) {
+			return false
+		}
+	}
+	return true
+}
+
+// The puzzle's extra regions in the same form as the variant regions
+func extraRegions(sg *SizedGrid) []region {
+	var regions []region
+	for i, cels := range sg.Extra {
+		regions = append(regions, region{Unit{"extra", i}, celIndexes(cels, sg.Size())})
+	}
+	return regions
+}
+
+//  Same as checkLogic for a sized grid with variant regions.  A step
+//  against the solution means a technique has misread the regions.
+
+func checkSizedLogic(t *testing.T, name string, puzzle *SizedGrid, soln *SizedGrid) {
+
+	var lg logicGrid
+	if err := lg.prepareLogical(context.Background(), puzzle); err != nil {
+		t.Error(fmt.Sprintf("%s: puzzle not prepared.  Returned: %v", name, err))
+		return
+	}
+	want := func(idx int) CelVal {
+		return soln.Cels[idx/soln.Size()][idx%soln.Size()]
+	}
+	err := lg.solveLogical(context.Background(), func(st *step) {
+		for _, p := range st.placed {
+			if p.val != want(p.idx) {
+				t.Error(fmt.Sprintf("%s: %v placed %d at %v", name, st.tech, p.val, lg.geo.celOf(p.idx)))
+			}
+		}
+		for _, e := range st.elims {
+			if e.val == want(e.idx) {
+				t.Error(fmt.Sprintf("%s: %v eliminated %d at %v", name, st.tech, e.val, lg.geo.celOf(e.idx)))
+			}
+		}
+	})
+	if err != nil {
+		t.Error(fmt.Sprintf("%s: logic failed.  Returned: %v", name, err))
+	}
+}
+
+func TestDiagonals(t *testing.T) {
+
+	// Blank grids of each size fill with distinct diagonals
+	for _, shape := range []Shape{{2, 2}, {2, 3}, {3, 3}, {3, 4}} {
+		for _, solver := range []Solver{Backtrack, DancingLinks} {
+			sg := NewSizedGrid(shape)
+			sg.Diagonals = true
+			err := SolveSizedWith(context.Background(), solver, &sg)
+			if err != nil || !solvedSized(&sg) || !diagonalsHold(&sg) {
+				t.Error(fmt.Sprintf("%dx%d boxes, %T: diagonals broken.  Returned: %v",
+					shape.BoxRows, shape.BoxCols, solver, err))
+			}
+		}
+	}
+
+	// A clash on a diagonal only counts under the variant
+	sg := NewSizedGrid(classic.shape)
+	sg.Cels[0][0], sg.Cels[8][8] = 5, 5
+	if conflicts, _ := ValidateSized(&sg); len(conflicts) != 0 {
+		t.Error(fmt.Sprintf("Standard grid reported %v", conflicts))
+	}
+	sg.Diagonals = true
+	conflicts, err := ValidateSized(&sg)
+	if err != nil || len(conflicts) != 1 || conflicts[0].Unit != (Unit{"diagonal", 0}) {
+		t.Error(fmt.Sprintf("Diagonal clash not reported.  Returned: %v, %v", conflicts, err))
+	}
+	if err := SolveSized(&sg); ErrorCode(err) != "conflict" {
+		t.Error(fmt.Sprintf("Expected ErrConflict.  Returned: %v", err))
+	}
+}
+
+func TestJsolveDiagonals(t *testing.T) {
+
+	var jGrid JsonGrid
+	if err := json.Unmarshal([]byte(`{"diagonals": true}`), &jGrid); err != nil || !jGrid.Diagonals {
+		t.Fatal(fmt.Sprintf("Variant not decoded.  Returned: %v", err))
+	}
+
+	Jsolve(&jGrid)
+	soln := SizedGridOf(&jGrid.Solution)
+	if jGrid.Code != "" || !diagonalsHold(&soln) {
+		t.Error(fmt.Sprintf("Jsolve ignored the variant.  Returned: %s", jGrid.Status))
+	}
+}
+
+func TestGenerateDiagonals(t *testing.T) {
+
+	opts := GenerateOptions{Seed: 5, Difficulty: AnyDifficulty, Variants: Variants{Diagonals: true}}
+	gen, err := Generate(opts)
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Generate failed.  Returned: %v", err))
+	}
+
+	soln := SizedGridOf(&gen.Solution)
+	if !diagonalsHold(&soln) {
+		t.Error("Generated solution breaks the diagonals")
+	}
+
+	puzzle := SizedGridOf(&gen.Puzzle)
+	puzzle.Variants = opts.Variants
+	for _, solver := range []Solver{Backtrack, DancingLinks} {
+		if n, err := CountSolutionsSizedWith(context.Background(), solver, &puzzle, 2); err != nil || n != 1 {
+			t.Error(fmt.Sprintf("%T: puzzle not unique.  Returned: %d, %v", solver, n, err))
+		}
+	}
+	if err := SolveSized(&puzzle); err != nil || fmt.Sprint(puzzle.Cels) != fmt.Sprint(soln.Cels) {
+		t.Error(fmt.Sprintf("Solution does not match.  Returned: %v", err))
+	}
+
+	jGen := JsonGenerated{Seed: opts.Seed, Difficulty: AnyDifficulty, Variants: opts.Variants}
+	Jgenerate(&jGen)
+	if jGen.Code != "" || jGen.Puzzle != gen.Puzzle || !jGen.Diagonals {
+		t.Error(fmt.Sprintf("Jgenerate ignored the variant.  Returned: %s", jGen.Status))
+	}
+}
+
+func TestGenerateNonConsecutive(t *testing.T) {
+
+	// A clue target keeps the search for a unique puzzle short
+	opts := GenerateOptions{Seed: 4, Clues: 24, Difficulty: AnyDifficulty, Variants: Variants{NonConsecutive: true}}
+	gen, err := Generate(opts)
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Generate failed.  Returned: %v", err))
+	}
+
+	soln := SizedGridOf(&gen.Solution)
+	if !nonConsecutiveHolds(&soln) {
+		t.Error("Generated solution has consecutive neighbours")
+	}
+
+	puzzle := SizedGridOf(&gen.Puzzle)
+	puzzle.Variants = opts.Variants
+	for _, solver := range []Solver{Backtrack, DancingLinks} {
+		if n, err := CountSolutionsSizedWith(context.Background(), solver, &puzzle, 2); err != nil || n != 1 {
+			t.Error(fmt.Sprintf("%T: puzzle not unique.  Returned: %d, %v", solver, n, err))
+		}
+	}
+	checkSizedLogic(t, "non-consecutive", &puzzle, &soln)
+}
+
+func TestWindows(t *testing.T) {
+
+	// Four windows on the standard grid, the first at rows and columns 1 to 3
+	windows := Variants{Windows: true}.regions(classic.shape)
+	if len(windows) != 4 || windows[0].cels[0] != 10 || windows[3].cels[8] != 70 {
+		t.Error(fmt.Sprintf("Wrong windows.  Returned: %v", windows))
+	}
+
+	// Blank grids of each size fill with distinct windows
+	for _, shape := range []Shape{{2, 2}, {2, 3}, {3, 3}, {3, 4}} {
+		for _, solver := range []Solver{Backtrack, DancingLinks} {
+			sg := NewSizedGrid(shape)
+			sg.Windows = true
+			err := SolveSizedWith(context.Background(), solver, &sg)
+			if err != nil || !solvedSized(&sg) || !regionsHold(&sg, sg.Variants.regions(shape)) {
+				t.Error(fmt.Sprintf("%dx%d boxes, %T: windows broken.  Returned: %v",
+					shape.BoxRows, shape.BoxCols, solver, err))
+			}
+		}
+	}
+
+	// A clash in a window that crosses two boxes
+	sg := NewSizedGrid(classic.shape)
+	sg.Windows = true
+	sg.Cels[1][1], sg.Cels[3][3] = 4, 4
+	conflicts, err := ValidateSized(&sg)
+	if err != nil || len(conflicts) != 1 || conflicts[0].Unit != (Unit{"window", 0}) {
+		t.Error(fmt.Sprintf("Window clash not reported.  Returned: %v, %v", conflicts, err))
+	}
+}
+
+func TestGenerateWindows(t *testing.T) {
+
+	opts := GenerateOptions{Seed: 3, Difficulty: AnyDifficulty, Variants: Variants{Windows: true}}
+	gen, err := Generate(opts)
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Generate failed.  Returned: %v", err))
+	}
+
+	soln := SizedGridOf(&gen.Solution)
+	if !regionsHold(&soln, opts.Variants.regions(classic.shape)) {
+		t.Error("Generated solution breaks the windows")
+	}
+
+	puzzle := SizedGridOf(&gen.Puzzle)
+	puzzle.Variants = opts.Variants
+	for _, solver := range []Solver{Backtrack, DancingLinks} {
+		if n, err := CountSolutionsSizedWith(context.Background(), solver, &puzzle, 2); err != nil || n != 1 {
+			t.Error(fmt.Sprintf("%T: puzzle not unique.  Returned: %d, %v", solver, n, err))
+		}
+	}
+	checkSizedLogic(t, "windows", &puzzle, &soln)
+
+	// The same rules given as extra regions, plus one with fewer cels
+	// that overlaps the windows, boxes and lines
+	extra := SizedGridOf(&gen.Puzzle)
+	for _, r := range opts.Variants.regions(classic.shape) {
+		var cels []Cel
+		for _, idx := range r.cels {
+			cels = append(cels, classic.celOf(idx))
+		}
+		extra.Extra = append(extra.Extra, cels)
+	}
+	var cels []Cel
+	var vals valSet
+	for i := 0; i < GridSize; i++ {
+		if val := soln.Cels[i][GridSize-1-i]; !vals.has(val) && len(cels) < 5 {
+			cels = append(cels, Cel{i, GridSize - 1 - i})
+			vals |= valBit(val)
+		}
+	}
+	extra.Extra = append(extra.Extra, cels)
+	checkSizedLogic(t, "extra", &extra, &soln)
+}
+
+func TestExtraRegions(t *testing.T) {
+
+	// A full diagonal, and a partial one crossing it in the centre
+	sg := NewSizedGrid(classic.shape)
+	sg.Extra = [][]Cel{
+		{{0, 0}, {1, 1}, {2, 2}, {3, 3}, {4, 4}, {5, 5}, {6, 6}, {7, 7}, {8, 8}},
+		{{2, 6}, {3, 5}, {4, 4}, {5, 3}, {6, 2}},
+	}
+	for _, solver := range []Solver{Backtrack, DancingLinks} {
+		soln := sg
+		err := SolveSizedWith(context.Background(), solver, &soln)
+		if err != nil || !solvedSized(&soln) || !regionsHold(&soln, extraRegions(&soln)) {
+			t.Error(fmt.Sprintf("%T: extra regions broken.  Returned: %v", solver, err))
+		}
+	}
+
+	sg.Cels[2][6], sg.Cels[6][2] = 7, 7
+	conflicts, err := ValidateSized(&sg)
+	if err != nil || len(conflicts) != 1 || conflicts[0].Unit != (Unit{"extra", 1}) {
+		t.Error(fmt.Sprintf("Extra region clash not reported.  Returned: %v, %v", conflicts, err))
+	}
+
+	bad := map[string][][]Cel{
+		"empty":    {{}},
+		"too long": {make([]Cel, GridSize+1)},
+		"off grid": {{{0, 0}, {GridSize, 0}}},
+		"repeated": {{{0, 0}, {1, 1}, {0, 0}}},
+	}
+	for name, extra := range bad {
+		sg := NewSizedGrid(classic.shape)
+		sg.Extra = extra
+		if err := SolveSized(&sg); !errors.Is(err, ErrBadRegions) {
+			t.Error(fmt.Sprintf("%s: expected ErrBadRegions.  Returned: %v", name, err))
+		}
+	}
+}
+
+func TestJsolveExtra(t *testing.T) {
+
+	body := `{"windows": true, "extra": [[{"row": 0, "col": 0}, {"row": 8, "col": 8}]]}`
+	var jGrid JsonGrid
+	if err := json.Unmarshal([]byte(body), &jGrid); err != nil || !jGrid.Windows || len(jGrid.Extra) != 1 {
+		t.Fatal(fmt.Sprintf("Regions not decoded.  Returned: %v", err))
+	}
+
+	Jsolve(&jGrid)
+	soln := SizedGridOf(&jGrid.Solution)
+	soln.Windows, soln.Extra = true, jGrid.Extra
+	if jGrid.Code != "" || !solvedSized(&soln) || soln.Cels[0][0] == soln.Cels[8][8] {
+		t.Error(fmt.Sprintf("Jsolve ignored the regions.  Returned: %s", jGrid.Status))
+	}
+
+	jGrid.Extra[0] = append(jGrid.Extra[0], Cel{9, 9})
+	Jsolve(&jGrid)
+	if jGrid.Code != "bad_regions" {
+		t.Error(fmt.Sprintf("Failed to catch a bad extra region.  Returned: %s", jGrid.Status))
+	}
+}
+
+// True if no two cels a move apart under the chess variants hold the
+// same value
+func movesHold(sg *SizedGrid) bool {
+	size := sg.Size()
+	for _, rule := range sg.Variants.moves(size) {
+		for a, cels := range rule.cels {
+			for _, b := range cels {
+				if sg.Cels[a/size][a%size] == sg.Cels[b/size][b%size] {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+func TestChessMoves(t *testing.T) {
+
+	// A corner has two knight's moves and a middle cel eight king's moves
+	moves := Variants{AntiKnight: true, AntiKing: true}.moves(GridSize)
+	if len(moves) != 2 || len(moves[0].cels[0]) != 2 || len(moves[1].cels[40]) != 8 {
+		t.Error(fmt.Sprintf("Wrong moves.  Returned: %v", moves))
+	}
+
+	// Blank grids fill under each rule and both together
+	for _, v := range []Variants{{AntiKnight: true}, {AntiKing: true}, {AntiKnight: true, AntiKing: true}} {
+		for _, shape := range []Shape{{2, 3}, {3, 3}} {
+			for _, solver := range []Solver{Backtrack, DancingLinks} {
+				sg := NewSizedGrid(shape)
+				sg.Variants = v
+				if shape.Size() == 6 && v.AntiKnight && v.AntiKing {
+					// Both together leave no solution at this size
+					if err := SolveSizedWith(context.Background(), solver, &sg); err != ErrUnsolvable {
+						t.Error(fmt.Sprintf("%+v, %T: expected ErrUnsolvable.  Returned: %v", v, solver, err))
+					}
+					continue
+				}
+				err := SolveSizedWith(context.Background(), solver, &sg)
+				if err != nil || !solvedSized(&sg) || !movesHold(&sg) {
+					t.Error(fmt.Sprintf("%+v, %dx%d boxes, %T: moves broken.  Returned: %v",
+						v, shape.BoxRows, shape.BoxCols, solver, err))
+				}
+			}
+		}
+	}
+
+	// A clash a knight's move apart, across two boxes
+	sg := NewSizedGrid(classic.shape)
+	sg.Cels[2][2], sg.Cels[3][4] = 6, 6
+	if conflicts, _ := ValidateSized(&sg); len(conflicts) != 0 {
+		t.Error(fmt.Sprintf("Standard grid reported %v", conflicts))
+	}
+	sg.AntiKnight = true
+	conflicts, err := ValidateSized(&sg)
+	if err != nil || len(conflicts) != 1 || conflicts[0].Unit != (Unit{"knight", 0}) {
+		t.Error(fmt.Sprintf("Knight clash not reported.  Returned: %v, %v", conflicts, err))
+	}
+	var celErr *CelError
+	if err := SolveSized(&sg); !errors.As(err, &celErr) || celErr.Unit != (Unit{"knight", 0}) {
+		t.Error(fmt.Sprintf("Expected ErrConflict.  Returned: %v", err))
+	}
+
+	// checkGrid rejects a standard solution with a clash a move apart
+	var soln Grid = hardGrid
+	Solve(&soln)
+	standard := SizedGridOf(&soln)
+	for _, v := range []Variants{{}, {AntiKing: true}} {
+		geo, _ := geometryOf(classic.shape, rules{variants: v})
+		var gp grid
+		gp.init(geo)
+		for idx := 0; idx < geo.numCels; idx++ {
+			gp.place(idx, standard.Cels[idx/GridSize][idx%GridSize])
+		}
+		standard.Variants = v
+		if gp.checkGrid() != movesHold(&standard) {
+			t.Error(fmt.Sprintf("%+v: checkGrid returned %v", v, gp.checkGrid()))
+		}
+	}
+}
+
+func TestChessCandidates(t *testing.T) {
+
+	// A knight's move from a 5 in the centre
+	var puzzle Grid
+	puzzle[4][4] = 5
+	jCands := JsonCandidates{Puzzle: puzzle}
+	Jcandidates(&jCands)
+	if jCands.Code != "" || fmt.Sprint(jCands.Candidates[2][3]) != "[1 2 3 4 5 6 7 8 9]" {
+		t.Error(fmt.Sprintf("Standard candidates wrong.  Returned: %v, %s", jCands.Candidates[2][3], jCands.Status))
+	}
+	jCands.AntiKnight = true
+	Jcandidates(&jCands)
+	if jCands.Code != "" || fmt.Sprint(jCands.Candidates[2][3]) != "[1 2 3 4 6 7 8 9]" {
+		t.Error(fmt.Sprintf("Knight's move not removed.  Returned: %v, %s", jCands.Candidates[2][3], jCands.Status))
+	}
+}
+
+func TestGenerateChess(t *testing.T) {
+
+	opts := GenerateOptions{Seed: 2, Difficulty: AnyDifficulty, Variants: Variants{AntiKnight: true, AntiKing: true}}
+	gen, err := Generate(opts)
+	if err != nil {
+		t.Fatal(fmt.Sprintf("Generate failed.  Returned: %v", err))
+	}
+
+	soln := SizedGridOf(&gen.Solution)
+	soln.Variants = opts.Variants
+	if !movesHold(&soln) {
+		t.Error("Generated solution breaks the moves")
+	}
+
+	puzzle := SizedGridOf(&gen.Puzzle)
+	puzzle.Variants = opts.Variants
+	for _, solver := range []Solver{Backtrack, DancingLinks} {
+		if n, err := CountSolutionsSizedWith(context.Background(), solver, &puzzle, 2); err != nil || n != 1 {
+			t.Error(fmt.Sprintf("%T: puzzle not unique.  Returned: %d, %v", solver, n, err))
+		}
+	}
+	checkSizedLogic(t, "chess", &puzzle, &soln)
+}